@@ -1,11 +1,29 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	terraApi "github.com/osallou/goterra-cli/lib/api"
+	terraBundle "github.com/osallou/goterra-cli/lib/bundle"
+	terraConfig "github.com/osallou/goterra-cli/lib/config"
+	terraOutput "github.com/osallou/goterra-cli/lib/output"
+	terraPrompt "github.com/osallou/goterra-cli/lib/prompt"
+	terraSpec "github.com/osallou/goterra-cli/lib/spec"
 	terraModel "github.com/osallou/goterra-lib/lib/model"
 	terraUser "github.com/osallou/goterra-lib/lib/user"
 )
@@ -13,55 +31,332 @@ import (
 // Version is client version
 var Version string
 
+// defaultURL is the Goterra host used if none is configured
+const defaultURL = "https://goterra.genouest.org"
+
+// defaultNamespace is the namespace id used by -ns options if not set, from GOT_NAMESPACE or profile
+var defaultNamespace string
+
+// Process exit codes, 2 is used by flag on invalid options
+const (
+	exitError        = 1
+	exitUnauthorized = 3
+	exitForbidden    = 4
+	exitNotFound     = 5
+	exitServerError  = 6
+	exitCancelled    = 7
+)
+
+// exitCode maps an error to the process exit code
+func exitCode(err error) int {
+	switch {
+	case terraApi.IsUnauthorized(err):
+		return exitUnauthorized
+	case terraApi.IsForbidden(err):
+		return exitForbidden
+	case terraApi.IsNotFound(err):
+		return exitNotFound
+	case terraApi.IsServerError(err):
+		return exitServerError
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return exitCancelled
+	}
+	return exitError
+}
+
+// stringList is a string list option, repeatable and comma separated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set appends comma separated values
+func (l *stringList) Set(value string) error {
+	for _, elt := range strings.Split(value, ",") {
+		if elt = strings.TrimSpace(elt); elt != "" {
+			*l = append(*l, elt)
+		}
+	}
+	return nil
+}
+
+// filterList is a repeatable list filter option
+type filterList struct {
+	options *terraOutput.ListOptions
+}
+
+func (l filterList) String() string {
+	if l.options == nil {
+		return ""
+	}
+	filters := make([]string, len(l.options.Filters))
+	for i, filter := range l.options.Filters {
+		filters[i] = filter.String()
+	}
+	return strings.Join(filters, " ")
+}
+
+// Set parses and appends a filter
+func (l filterList) Set(value string) error {
+	filter, err := terraOutput.ParseFilter(value)
+	if err != nil {
+		return err
+	}
+	l.options.Filters = append(l.options.Filters, filter)
+	return nil
+}
+
+// addListFlags adds column selection, sort and filter options of list commands to printer list options
+func addListFlags(cmdOptions *flag.FlagSet, printer *terraOutput.Printer) {
+	cmdOptions.Var((*stringList)(&printer.List.Columns), "columns", "comma separated columns to display, e.g. id,name,status")
+	cmdOptions.StringVar(&printer.List.SortBy, "sort-by", "", "column to sort on, prefix with - for descending order, e.g. -start")
+	cmdOptions.Var(filterList{options: &printer.List}, "filter", "repeatable COLUMN OPERATOR VALUE filter, operators: = != ~ !~ < <= > >=, e.g. status=deploy_failed, start>2026-01-01, name~^test-")
+}
+
 // ShowUsage display base options
 func ShowUsage(options []string) {
 	fmt.Println("Usage:")
 	fmt.Println("  Expected environment variables:")
 	fmt.Println("    * GOT_APIKEY: user api key")
 	fmt.Println("    * GOT_URL: URL to goterra")
+	fmt.Println("    * GOT_RETRIES: max attempts for idempotent requests (optional)")
+	fmt.Println("    * GOT_PROFILE: configuration profile (optional)")
+	fmt.Println("    * GOT_NAMESPACE: default namespace id (optional)")
+	fmt.Println("    * GOT_CONFIG: configuration file path (optional)")
+	fmt.Println("    * GOT_OUTPUT: default output format (optional)")
 	for _, option := range options {
 		fmt.Printf("  goterra-cli %s -h\n", option)
 	}
 }
 
-func handleEditNamespace(options terraApi.OptionsDef, nsID string, args []string) error {
+// nsEdit is a set of changes to apply to a namespace
+type nsEdit struct {
+	addOwners     []string
+	removeOwners  []string
+	addMembers    []string
+	removeMembers []string
+	// sync replaces members with syncMembers before other member changes
+	sync        bool
+	syncMembers []string
+	freeze      *bool
+}
+
+// apply returns a copy of ns with edit changes
+func (e nsEdit) apply(ns terraModel.NSData) terraModel.NSData {
+	updated := ns
+	updated.Owners = append([]string{}, ns.Owners...)
+	updated.Members = append([]string{}, ns.Members...)
+	if e.sync {
+		updated.Members = []string{}
+		for _, member := range e.syncMembers {
+			updated.Members = terraApi.AddToList(updated.Members, member)
+		}
+	}
+	for _, owner := range e.addOwners {
+		updated.Owners = terraApi.AddToList(updated.Owners, owner)
+	}
+	for _, owner := range e.removeOwners {
+		updated.Owners = terraApi.RemoveFromList(updated.Owners, owner)
+	}
+	for _, member := range e.addMembers {
+		updated.Members = terraApi.AddToList(updated.Members, member)
+	}
+	for _, member := range e.removeMembers {
+		updated.Members = terraApi.RemoveFromList(updated.Members, member)
+	}
+	if e.freeze != nil {
+		updated.Freeze = *e.freeze
+	}
+	return updated
+}
+
+// conflicts returns edit changes opposed to the changes made by someone else between snapshot and current namespace
+func (e nsEdit) conflicts(snapshot terraModel.NSData, current terraModel.NSData) []string {
+	var conflicts []string
+	addedOwners, removedOwners := terraApi.DiffList(snapshot.Owners, current.Owners)
+	addedMembers, removedMembers := terraApi.DiffList(snapshot.Members, current.Members)
+	for _, owner := range e.addOwners {
+		if inList(removedOwners, owner) {
+			conflicts = append(conflicts, fmt.Sprintf("owner %s was removed, edit adds it", owner))
+		}
+	}
+	for _, owner := range e.removeOwners {
+		if inList(addedOwners, owner) {
+			conflicts = append(conflicts, fmt.Sprintf("owner %s was added, edit removes it", owner))
+		}
+	}
+	if e.sync && (len(addedMembers) > 0 || len(removedMembers) > 0) {
+		conflicts = append(conflicts, fmt.Sprintf("members were modified (added: %s, removed: %s), -sync would overwrite them", strings.Join(addedMembers, ","), strings.Join(removedMembers, ",")))
+	} else {
+		for _, member := range e.addMembers {
+			if inList(removedMembers, member) {
+				conflicts = append(conflicts, fmt.Sprintf("member %s was removed, edit adds it", member))
+			}
+		}
+		for _, member := range e.removeMembers {
+			if inList(addedMembers, member) {
+				conflicts = append(conflicts, fmt.Sprintf("member %s was added, edit removes it", member))
+			}
+		}
+	}
+	if e.freeze != nil && current.Freeze != snapshot.Freeze && current.Freeze != *e.freeze {
+		conflicts = append(conflicts, fmt.Sprintf("freeze was set to %t, edit sets it to %t", current.Freeze, *e.freeze))
+	}
+	return conflicts
+}
+
+// sameNamespace checks if namespaces have the same name, owners, members and freeze state
+func sameNamespace(ns terraModel.NSData, other terraModel.NSData) bool {
+	addedOwners, removedOwners := terraApi.DiffList(ns.Owners, other.Owners)
+	addedMembers, removedMembers := terraApi.DiffList(ns.Members, other.Members)
+	return ns.Name == other.Name && ns.Freeze == other.Freeze &&
+		len(addedOwners)+len(removedOwners)+len(addedMembers)+len(removedMembers) == 0
+}
+
+// printNamespaceChanges displays changes between ns and updated namespace
+//
+// It returns if there are changes, and if some of them remove owners or members
+func printNamespaceChanges(ns terraModel.NSData, updated terraModel.NSData) (changed bool, removing bool) {
+	for _, list := range []struct {
+		name   string
+		before []string
+		after  []string
+	}{
+		{"Owners", ns.Owners, updated.Owners},
+		{"Members", ns.Members, updated.Members},
+	} {
+		added, removed := terraApi.DiffList(list.before, list.after)
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+		fmt.Printf("%s: %d added, %d removed\n", list.name, len(added), len(removed))
+		for _, elt := range added {
+			fmt.Printf("  + %s\n", elt)
+		}
+		for _, elt := range removed {
+			fmt.Printf("  - %s\n", elt)
+		}
+		changed = true
+		removing = removing || len(removed) > 0
+	}
+	if ns.Freeze != updated.Freeze {
+		fmt.Printf("Freeze: %t -> %t\n", ns.Freeze, updated.Freeze)
+		changed = true
+	}
+	return changed, removing
+}
+
+// readUserFile reads user ids from a file, or stdin if path is -, one per line
+//
+// Empty lines and lines starting with # are ignored
+func readUserFile(path string) ([]string, error) {
+	file := os.Stdin
+	if path != "-" {
+		var openErr error
+		file, openErr = os.Open(path)
+		if openErr != nil {
+			return nil, openErr
+		}
+		defer file.Close()
+	}
+	var users []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		users = append(users, line)
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", path, scanErr)
+	}
+	return users, nil
+}
+
+func handleEditNamespace(ctx context.Context, client *terraApi.Client, nsID string, args []string) error {
+	var edit nsEdit
 	cmdOptions := flag.NewFlagSet("edit options", flag.ExitOnError)
-	addOwner := cmdOptions.String("add-owner", "", "Add an owner")
-	addMember := cmdOptions.String("add-member", "", "Add a member")
-	removeOwner := cmdOptions.String("remove-owner", "", "Remove an owner")
-	removeMember := cmdOptions.String("remove-member", "", "Remove a member")
+	cmdOptions.Var((*stringList)(&edit.addOwners), "add-owner", "Add owners, repeatable or comma separated")
+	cmdOptions.Var((*stringList)(&edit.addMembers), "add-member", "Add members, repeatable or comma separated")
+	cmdOptions.Var((*stringList)(&edit.removeOwners), "remove-owner", "Remove owners, repeatable or comma separated")
+	cmdOptions.Var((*stringList)(&edit.removeMembers), "remove-member", "Remove members, repeatable or comma separated")
+	membersFile := cmdOptions.String("members-file", "", "Add members listed in file, one per line, - for stdin")
+	cmdOptions.BoolVar(&edit.sync, "sync", false, "Set members to the exact list of -members-file")
 	freeze := cmdOptions.Bool("freeze", false, "freeze namespace")
 	unfreeze := cmdOptions.Bool("unfreeze", false, "unfreeze namespace")
+	dryRun := cmdOptions.Bool("dry-run", false, "Only show changes")
+	yes := cmdOptions.Bool("yes", false, "Do not ask for confirmation when removing owners or members")
 	if len(args) == 0 {
 		cmdOptions.PrintDefaults()
 		return nil
 	}
 	cmdOptions.Parse(args)
 
-	ns, err := terraApi.GetNamespace(options, nsID)
-	if err != nil {
-		return err
+	if *freeze && *unfreeze {
+		return fmt.Errorf("-freeze and -unfreeze are exclusive")
+	}
+	if *freeze || *unfreeze {
+		edit.freeze = freeze
+	}
+	if *membersFile != "" {
+		members, readErr := readUserFile(*membersFile)
+		if readErr != nil {
+			return readErr
+		}
+		if edit.sync {
+			edit.syncMembers = members
+		} else {
+			edit.addMembers = append(edit.addMembers, members...)
+		}
+	} else if edit.sync {
+		return fmt.Errorf("-sync requires -members-file")
 	}
 
-	if *addOwner != "" {
-		ns.Owners = terraApi.AddToList(ns.Owners, *addOwner)
+	ns, err := client.GetNamespace(ctx, nsID)
+	if err != nil {
+		return err
 	}
-	if *addMember != "" {
-		ns.Members = terraApi.AddToList(ns.Members, *addMember)
+	updated := edit.apply(*ns)
+	changed, removing := printNamespaceChanges(*ns, updated)
+	if !changed {
+		fmt.Println("Namespace already up to date")
+		return nil
 	}
-	if *removeOwner != "" {
-		ns.Owners = terraApi.RemoveFromList(ns.Owners, *removeOwner)
+	if *dryRun {
+		return nil
 	}
-	if *removeMember != "" {
-		ns.Members = terraApi.RemoveFromList(ns.Members, *removeMember)
+	if removing && !*yes {
+		if *membersFile == "-" {
+			return fmt.Errorf("members read from stdin, use -yes to confirm removals")
+		}
+		if !promptConfirm("Please confirm removals") {
+			return nil
+		}
 	}
-	if *freeze == true {
-		ns.Freeze = true
+
+	// Namespace may have been modified since it was read, check again just before writing
+	current, err := client.GetNamespace(ctx, nsID)
+	if err != nil {
+		return err
 	}
-	if *unfreeze == true {
-		ns.Freeze = false
+	if !sameNamespace(*ns, *current) {
+		if conflicts := edit.conflicts(*ns, *current); len(conflicts) > 0 {
+			fmt.Println("Namespace was modified since it was read, conflicting changes:")
+			for _, conflict := range conflicts {
+				fmt.Printf("  * %s\n", conflict)
+			}
+			return fmt.Errorf("namespace edit aborted because of conflicts, nothing was changed")
+		}
+		updated = edit.apply(*current)
+		fmt.Println("Namespace was modified since it was read, changes applied to current namespace:")
+		if changed, _ := printNamespaceChanges(*current, updated); !changed {
+			fmt.Println("Namespace already up to date")
+			return nil
+		}
 	}
-	err = terraApi.UpdateNamespace(options, ns)
+	err = client.UpdateNamespace(ctx, &updated)
 	if err != nil {
 		return err
 	}
@@ -69,36 +364,352 @@ func handleEditNamespace(options terraApi.OptionsDef, nsID string, args []string
 	return nil
 }
 
-func handleNamespace(options terraApi.OptionsDef, args []string) error {
+// completeNamespace updates a created namespace with owners, members and freeze state of ns ignored at creation
+func completeNamespace(ctx context.Context, client *terraApi.Client, created *terraModel.NSData, ns *terraModel.NSData) error {
+	updated := created.Freeze != ns.Freeze
+	for _, owner := range ns.Owners {
+		if !inList(created.Owners, owner) {
+			created.Owners = terraApi.AddToList(created.Owners, owner)
+			updated = true
+		}
+	}
+	for _, member := range ns.Members {
+		if !inList(created.Members, member) {
+			created.Members = terraApi.AddToList(created.Members, member)
+			updated = true
+		}
+	}
+	if !updated {
+		return nil
+	}
+	created.Freeze = ns.Freeze
+	return client.UpdateNamespace(ctx, created)
+}
+
+// login sets client token, from cache if still valid, else with api key
+func login(ctx context.Context, client *terraApi.Client, tokenCache *terraConfig.TokenCache, tokenKey string) error {
+	if token, ok := tokenCache.Get(tokenKey); ok {
+		client.SetToken(token)
+		return nil
+	}
+	token, loginErr := client.Login(ctx)
+	if loginErr != nil {
+		return loginErr
+	}
+	client.SetToken(token)
+	cacheToken(client, tokenCache, tokenKey)
+	return nil
+}
+
+// cacheToken saves client token in cache, failures are only reported
+func cacheToken(client *terraApi.Client, tokenCache *terraConfig.TokenCache, tokenKey string) {
+	token := client.CurrentToken()
+	expiry, _ := terraApi.TokenExpiry(token)
+	if cacheErr := tokenCache.Put(tokenKey, token, expiry); cacheErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to cache token: %s\n", cacheErr)
+	}
+}
+
+// authSettings holds the settings used by auth commands
+type authSettings struct {
+	config      *terraConfig.Config
+	configPath  string
+	profileName string
+	tokenCache  *terraConfig.TokenCache
+	tokenKey    string
+}
+
+func handleAuthLogin(ctx context.Context, client *terraApi.Client, settings *authSettings) error {
+	apiKey, promptErr := terraPrompt.Secret("API key")
+	if promptErr != nil {
+		return promptErr
+	}
+	if apiKey == "" {
+		return fmt.Errorf("empty api key")
+	}
+	client.APIKey = apiKey
+	authData, authErr := client.Authenticate(ctx)
+	if authErr != nil {
+		return authErr
+	}
+	client.SetToken(authData.Token)
+
+	profileName := firstNonEmpty(settings.profileName, settings.config.DefaultProfile, "default")
+	profile := settings.config.Profiles[profileName]
+	profile.URL = client.BaseURL
+	profile.APIKey = apiKey
+	profile.APIKeyCommand = ""
+	settings.config.Profiles[profileName] = profile
+	if settings.config.DefaultProfile == "" {
+		settings.config.DefaultProfile = profileName
+	}
+	if saveErr := settings.config.Save(settings.configPath); saveErr != nil {
+		return saveErr
+	}
+	settings.tokenKey = terraConfig.TokenKey(profileName, client.BaseURL, apiKey)
+	cacheToken(client, settings.tokenCache, settings.tokenKey)
+	fmt.Printf("Logged in as %s, api key saved in profile %s (%s)\n", authData.User.UID, profileName, settings.configPath)
+	return nil
+}
+
+func handleAuthWhoami(ctx context.Context, client *terraApi.Client, settings *authSettings) error {
+	authData, authErr := client.Authenticate(ctx)
+	if authErr != nil {
+		return authErr
+	}
+	client.SetToken(authData.Token)
+	cacheToken(client, settings.tokenCache, settings.tokenKey)
+
+	user := authData.User
+	kind := user.Kind
+	if kind == "" {
+		kind = "local"
+	}
+	fmt.Printf("uid: %s\n", user.UID)
+	fmt.Printf("email: %s\n", user.Email)
+	fmt.Printf("kind: %s\n", kind)
+	fmt.Printf("admin: %t\n", user.Admin)
+	fmt.Printf("super: %t\n", user.SuperUser)
+
+	namespaces, nsErr := client.GetNamespaces(ctx, false)
+	if nsErr != nil {
+		return nsErr
+	}
+	fmt.Println("namespaces:")
+	if len(namespaces) == 0 {
+		fmt.Println("\tnone")
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, '\t', tabwriter.AlignRight|tabwriter.Debug)
+	for _, ns := range namespaces {
+		role := "member"
+		if inList(ns.Owners, user.UID) {
+			role = "owner"
+		}
+		fmt.Fprintf(w, "\t%s\t%s\t%s\n", ns.ID.Hex(), ns.Name, role)
+	}
+	w.Flush()
+	return nil
+}
+
+func handleAuthStatus(ctx context.Context, client *terraApi.Client, settings *authSettings) error {
+	profileName := firstNonEmpty(settings.profileName, settings.config.DefaultProfile)
+	if profileName == "" {
+		profileName = "none"
+	}
+	fmt.Printf("server: %s\n", client.BaseURL)
+	fmt.Printf("profile: %s\n", profileName)
+	fmt.Printf("config: %s\n", settings.configPath)
+
+	token, hasToken := settings.tokenCache.Get(settings.tokenKey)
+	if !hasToken {
+		fmt.Println("token: none, not logged in")
+	} else if expiry, ok := terraApi.TokenExpiry(token); ok {
+		fmt.Printf("token: expires %s (in %s)\n", expiry.Format(time.RFC3339), time.Until(expiry).Round(time.Second))
+	} else {
+		fmt.Println("token: unknown expiry")
+	}
+
+	// Check without renewing token
+	client.APIKey = ""
+	client.SetToken(token)
+	statusCode, latency, pingErr := client.Ping(ctx)
+	if pingErr != nil {
+		fmt.Printf("reachable: false (%s)\n", pingErr)
+		return nil
+	}
+	fmt.Printf("reachable: true (HTTP %d, %s)\n", statusCode, latency.Round(time.Millisecond))
+	if hasToken {
+		fmt.Printf("token accepted: %t\n", statusCode != http.StatusUnauthorized && statusCode != http.StatusForbidden)
+	}
+	return nil
+}
+
+func handleAuth(ctx context.Context, client *terraApi.Client, settings *authSettings, args []string) error {
+	var err error
+
+	switch args[0] {
+	case "login":
+		err = handleAuthLogin(ctx, client, settings)
+		break
+	case "whoami":
+		err = handleAuthWhoami(ctx, client, settings)
+		break
+	case "status":
+		err = handleAuthStatus(ctx, client, settings)
+		break
+	case "logout":
+		cmdOptions := flag.NewFlagSet("logout options", flag.ExitOnError)
+		all := cmdOptions.Bool("all", false, "remove tokens of all profiles")
+		cmdOptions.Parse(args[1:])
+		if *all {
+			err = settings.tokenCache.Clear()
+			if err == nil {
+				fmt.Println("Logged out")
+			}
+			break
+		}
+		// Api key is not needed to logout, remove tokens of all api keys of profile
+		profileName := firstNonEmpty(settings.profileName, settings.config.DefaultProfile)
+		removed, deleteErr := settings.tokenCache.DeleteServer(profileName, client.BaseURL)
+		if deleteErr != nil {
+			err = deleteErr
+		} else if removed == 0 {
+			fmt.Printf("No cached token found for %s\n", client.BaseURL)
+		} else {
+			fmt.Println("Logged out")
+		}
+		break
+	default:
+		authUsage()
+	}
+	return err
+}
+
+func handleNamespace(ctx context.Context, client *terraApi.Client, printer *terraOutput.Printer, args []string) error {
 	var err error
 
 	switch args[0] {
 	case "list":
 		cmdOptions := flag.NewFlagSet("list options", flag.ExitOnError)
 		showAll := cmdOptions.Bool("all", false, "Get all namespaces [admin]")
+		addListFlags(cmdOptions, printer)
 		cmdOptions.Parse(args[1:])
-		err = terraApi.ListNamespaces(options, *showAll)
+		err = client.ListNamespaces(ctx, printer, *showAll)
 		break
 	case "show":
 		if len(args) == 1 {
 			return fmt.Errorf("missing namespace id")
 		}
-		err = terraApi.ShowNamespace(options, args[1])
+		err = client.ShowNamespace(ctx, printer, args[1])
 		break
 	case "edit":
 		if len(args) == 1 {
 			return fmt.Errorf("missing namespace id")
 		}
-		err = handleEditNamespace(options, args[1], args[2:])
+		err = handleEditNamespace(ctx, client, args[1], args[2:])
 		break
 	case "create":
-		if len(args) == 1 {
+		cmdOptions := flag.NewFlagSet("create options", flag.ExitOnError)
+		var owners, members stringList
+		cmdOptions.Var(&owners, "owner", "additional owner, repeatable or comma separated")
+		cmdOptions.Var(&members, "member", "member, repeatable or comma separated")
+		freeze := cmdOptions.Bool("freeze", false, "freeze namespace")
+		if len(args) == 1 || strings.HasPrefix(args[1], "-") {
+			fmt.Println("Usage: goterra namespace create NSNAME [options]")
+			cmdOptions.PrintDefaults()
 			return fmt.Errorf("missing namespace name")
 		}
+		cmdOptions.Parse(args[2:])
 		ns := terraModel.NSData{
-			Name: args[1],
+			Name:    args[1],
+			Owners:  owners,
+			Members: members,
+			Freeze:  *freeze,
+		}
+		created, createErr := client.CreateNamespace(ctx, &ns)
+		if createErr != nil {
+			return createErr
+		}
+		err = completeNamespace(ctx, client, created, &ns)
+		if err != nil {
+			return err
+		}
+		err = printer.PrintItem(terraOutput.Namespaces, *created)
+		break
+	case "report":
+		cmdOptions := flag.NewFlagSet("report options", flag.ExitOnError)
+		showAll := cmdOptions.Bool("all", false, "report all namespaces [admin]")
+		since := cmdOptions.String("since", "", "window start date (2006-01-02, 2006-01-02 15:04:05 or RFC3339)")
+		until := cmdOptions.String("until", "", "window end date, default now")
+		addListFlags(cmdOptions, printer)
+		nsID, options := subCommandArg(args)
+		cmdOptions.Parse(options)
+		sinceDate, sinceErr := parseDate(*since)
+		if sinceErr != nil {
+			return sinceErr
+		}
+		untilDate, untilErr := parseDate(*until)
+		if untilErr != nil {
+			return untilErr
+		}
+		var namespaces []terraModel.NSData
+		if nsID != "" {
+			ns, nsErr := client.GetNamespace(ctx, nsID)
+			if nsErr != nil {
+				return nsErr
+			}
+			namespaces = append(namespaces, *ns)
+		} else {
+			namespaces, err = client.GetNamespaces(ctx, *showAll)
+			if err != nil {
+				return err
+			}
+		}
+		reports := make([]terraOutput.NSReport, 0, len(namespaces))
+		for _, ns := range namespaces {
+			report, reportErr := client.NamespaceReport(ctx, ns, sinceDate, untilDate)
+			if reportErr != nil {
+				return reportErr
+			}
+			reports = append(reports, *report)
+		}
+		err = printer.PrintList(terraOutput.NSReports, reports)
+		break
+	case "export":
+		cmdOptions := flag.NewFlagSet("export options", flag.ExitOnError)
+		bundleFile := cmdOptions.String("f", "", "bundle file (tar.gz)")
+		if len(args) == 1 || strings.HasPrefix(args[1], "-") {
+			fmt.Println("Usage: goterra namespace export NSID -f bundle.tar.gz")
+			cmdOptions.PrintDefaults()
+			return fmt.Errorf("missing namespace id")
+		}
+		cmdOptions.Parse(args[2:])
+		if *bundleFile == "" {
+			return fmt.Errorf("missing bundle file")
+		}
+		bundle, exportErr := client.ExportNamespace(ctx, args[1])
+		if exportErr != nil {
+			return exportErr
+		}
+		err = bundle.Write(*bundleFile)
+		if err == nil {
+			fmt.Printf("Namespace %s exported to %s: %d endpoints, %d recipes, %d templates, %d applications\n", bundle.Manifest.NamespaceName, *bundleFile, len(bundle.Endpoints), len(bundle.Recipes), len(bundle.Templates), len(bundle.Apps))
+		}
+		break
+	case "import":
+		cmdOptions := flag.NewFlagSet("import options", flag.ExitOnError)
+		bundleFile := cmdOptions.String("f", "", "bundle file (tar.gz)")
+		target := cmdOptions.String("target", "", "target namespace id, a new namespace named as exported one is created if not set")
+		dryRun := cmdOptions.Bool("dry-run", false, "only list objects that would be created or skipped")
+		cmdOptions.Parse(args[1:])
+		if *bundleFile == "" {
+			cmdOptions.PrintDefaults()
+			return fmt.Errorf("missing bundle file")
+		}
+		bundle, readErr := terraBundle.Read(*bundleFile)
+		if readErr != nil {
+			return readErr
+		}
+		fmt.Printf("Bundle of namespace %s (%s) from %s, exported %s\n", bundle.Manifest.NamespaceName, bundle.Manifest.Namespace, bundle.Manifest.Source, terraOutput.FormatTime(bundle.Manifest.Exported))
+		nsID := *target
+		if nsID == "" {
+			if *dryRun {
+				fmt.Printf("create namespace %s\n", bundle.Manifest.NamespaceName)
+			} else {
+				ns, createErr := client.CreateNamespace(ctx, &terraModel.NSData{Name: bundle.Manifest.NamespaceName})
+				if createErr != nil {
+					return createErr
+				}
+				nsID = ns.ID.Hex()
+				fmt.Printf("create namespace %s => %s\n", ns.Name, nsID)
+			}
+		}
+		err = client.ImportNamespace(ctx, bundle, nsID, *dryRun, func(action terraApi.ImportAction) {
+			fmt.Println(action)
+		})
+		if err == nil && !*dryRun {
+			fmt.Printf("Bundle imported in namespace %s\n", nsID)
 		}
-		terraApi.CreateNamespace(options, &ns)
 		break
 	case "delete":
 		if len(args) == 1 {
@@ -106,56 +717,281 @@ func handleNamespace(options terraApi.OptionsDef, args []string) error {
 		}
 		confirm := promptConfirm("Please confirm deletion")
 		if confirm {
-			terraApi.DeleteNamespace(options, args[1])
+			err = client.DeleteNamespace(ctx, args[1])
 		}
 		break
 	}
 	return err
 }
 
-func handleEndpoint(options terraApi.OptionsDef, args []string) error {
+func handleEndpoint(ctx context.Context, client *terraApi.Client, printer *terraOutput.Printer, args []string) error {
 	var err error
 
 	switch args[0] {
 	case "list":
 		cmdOptions := flag.NewFlagSet("list options", flag.ExitOnError)
-		nsID := cmdOptions.String("ns", "", "namespace id")
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+		addListFlags(cmdOptions, printer)
 		cmdOptions.Parse(args[1:])
-		err = terraApi.ListEndpoints(options, *nsID)
+		err = client.ListEndpoints(ctx, printer, *nsID)
 		break
 	case "show":
 		cmdOptions := flag.NewFlagSet("show options", flag.ExitOnError)
-		nsID := cmdOptions.String("ns", "", "namespace id")
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
 		epID := cmdOptions.String("id", "", "endpoint id")
 		cmdOptions.Parse(args[1:])
 		if *nsID == "" && *epID == "" {
 			return fmt.Errorf("missing endpoint or namespace id")
 		}
-		err = terraApi.ShowEndpoint(options, *nsID, *epID)
+		err = client.ShowEndpoint(ctx, printer, *nsID, *epID)
+		break
+	case "create":
+		cmdOptions := flag.NewFlagSet("create options", flag.ExitOnError)
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+		definition := cmdOptions.String("f", "", "endpoint definition file (yaml)")
+		cmdOptions.Parse(args[1:])
+		if *nsID == "" || *definition == "" {
+			cmdOptions.PrintDefaults()
+			return fmt.Errorf("missing namespace id or definition file")
+		}
+		var endpoint terraModel.EndPoint
+		if loadErr := terraSpec.Load(*definition, &endpoint); loadErr != nil {
+			return loadErr
+		}
+		endpoint.Namespace = *nsID
+		if invalidErr := terraSpec.Invalid("endpoint", terraSpec.ValidateEndpoint(endpoint)); invalidErr != nil {
+			return invalidErr
+		}
+		epID, createErr := client.CreateEndpoint(ctx, *nsID, &endpoint)
+		if createErr != nil {
+			return createErr
+		}
+		err = client.ShowEndpoint(ctx, printer, *nsID, epID)
+		break
+	case "update":
+		cmdOptions := flag.NewFlagSet("update options", flag.ExitOnError)
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+		definition := cmdOptions.String("f", "", "endpoint definition file (yaml), replaces current definition")
+		epID, options := subCommandArg(args)
+		cmdOptions.Parse(options)
+		if epID == "" || *nsID == "" || *definition == "" {
+			fmt.Println("Usage: goterra endpoint update ID -f endpoint.yaml")
+			cmdOptions.PrintDefaults()
+			return fmt.Errorf("missing endpoint id, namespace id or definition file")
+		}
+		var endpoint terraModel.EndPoint
+		if loadErr := terraSpec.Load(*definition, &endpoint); loadErr != nil {
+			return loadErr
+		}
+		id, idErr := primitive.ObjectIDFromHex(epID)
+		if idErr != nil {
+			return fmt.Errorf("invalid endpoint id %s", epID)
+		}
+		endpoint.ID = id
+		endpoint.Namespace = *nsID
+		if invalidErr := terraSpec.Invalid("endpoint", terraSpec.ValidateEndpoint(endpoint)); invalidErr != nil {
+			return invalidErr
+		}
+		err = client.UpdateEndpoint(ctx, *nsID, &endpoint)
+		if err == nil {
+			fmt.Printf("Endpoint %s updated\n", epID)
+		}
+		break
+	case "secret":
+		err = handleEndpointSecret(ctx, client, args[1:])
+		break
+	case "defaults":
+		err = handleEndpointDefaults(ctx, client, printer, args[1:])
+		break
+	case "delete":
+		cmdOptions := flag.NewFlagSet("delete options", flag.ExitOnError)
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+		epID, options := subCommandArg(args)
+		cmdOptions.Parse(options)
+		if epID == "" || *nsID == "" {
+			return fmt.Errorf("missing endpoint or namespace id")
+		}
+		confirm := promptConfirm("Please confirm deletion")
+		if confirm {
+			err = client.DeleteEndpoint(ctx, *nsID, epID)
+		}
 		break
 	}
 	return err
 }
 
-func handleUser(options terraApi.OptionsDef, args []string) error {
+// readSecret reads a secret from file, stdin or a prompt without echo, trailing new line is removed
+func readSecret(file string, stdin bool) (string, error) {
+	var secret string
+	switch {
+	case file != "":
+		data, readErr := ioutil.ReadFile(file)
+		if readErr != nil {
+			return "", readErr
+		}
+		secret = string(data)
+	case stdin:
+		data, readErr := ioutil.ReadAll(os.Stdin)
+		if readErr != nil {
+			return "", readErr
+		}
+		secret = string(data)
+	default:
+		var promptErr error
+		secret, promptErr = terraPrompt.Secret("Secret")
+		if promptErr != nil {
+			return "", promptErr
+		}
+		if terraPrompt.Interactive() {
+			confirm, confirmErr := terraPrompt.Secret("Confirm secret")
+			if confirmErr != nil {
+				return "", confirmErr
+			}
+			if confirm != secret {
+				return "", fmt.Errorf("secrets do not match")
+			}
+		}
+	}
+	secret = strings.TrimRight(secret, "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("empty secret")
+	}
+	return secret, nil
+}
+
+func handleEndpointSecret(ctx context.Context, client *terraApi.Client, args []string) error {
+	if len(args) == 0 || !inList([]string{"set", "status", "delete"}, args[0]) {
+		endpointSecretUsage()
+		return nil
+	}
+	cmdOptions := flag.NewFlagSet("secret options", flag.ExitOnError)
+	nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+	var secretFile *string
+	var secretStdin *bool
+	if args[0] == "set" {
+		secretFile = cmdOptions.String("file", "", "read secret from file")
+		secretStdin = cmdOptions.Bool("stdin", false, "read secret from stdin")
+	}
+	epID, options := subCommandArg(args)
+	cmdOptions.Parse(options)
+	if epID == "" || *nsID == "" {
+		fmt.Printf("Usage: goterra endpoint secret %s ENDPOINTID -ns NSID\n", args[0])
+		cmdOptions.PrintDefaults()
+		return fmt.Errorf("missing endpoint or namespace id")
+	}
+
+	var err error
+	switch args[0] {
+	case "set":
+		secret, secretErr := readSecret(*secretFile, *secretStdin)
+		if secretErr != nil {
+			return secretErr
+		}
+		err = client.SetEndpointSecret(ctx, *nsID, epID, secret)
+		if err == nil {
+			fmt.Printf("Secret set for endpoint %s\n", epID)
+		}
+		break
+	case "status":
+		hasSecret, secretErr := client.HasEndpointSecret(ctx, *nsID, epID)
+		if secretErr != nil {
+			return secretErr
+		}
+		if hasSecret {
+			fmt.Printf("Secret is set for endpoint %s\n", epID)
+		} else {
+			fmt.Printf("No secret for endpoint %s\n", epID)
+		}
+		break
+	case "delete":
+		err = client.DeleteEndpointSecret(ctx, *nsID, epID)
+		if err == nil {
+			fmt.Printf("Secret deleted for endpoint %s\n", epID)
+		}
+		break
+	}
+	return err
+}
+
+func handleEndpointDefaults(ctx context.Context, client *terraApi.Client, printer *terraOutput.Printer, args []string) error {
+	if len(args) == 0 || !inList([]string{"show", "set", "unset"}, args[0]) {
+		endpointDefaultsUsage()
+		return nil
+	}
+	cmdOptions := flag.NewFlagSet("defaults options", flag.ExitOnError)
+	nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+	epID, options := subCommandArg(args)
+	cmdOptions.Parse(options)
+	if epID == "" || *nsID == "" {
+		fmt.Printf("Usage: goterra endpoint defaults %s ENDPOINTID -ns NSID\n", args[0])
+		cmdOptions.PrintDefaults()
+		return fmt.Errorf("missing endpoint or namespace id")
+	}
+	if args[0] == "show" {
+		return client.ShowEndpointDefaults(ctx, printer, *nsID, epID)
+	}
+	if cmdOptions.NArg() == 0 {
+		return fmt.Errorf("missing inputs, usage: goterra endpoint defaults set ENDPOINTID -ns NSID INPUT=VALUE... or unset ENDPOINTID -ns NSID INPUT...")
+	}
+
+	defaults, err := client.GetEndpointDefaults(ctx, *nsID, epID)
+	if err != nil {
+		return err
+	}
+	if defaults == nil {
+		defaults = make(map[string][]string)
+	}
+	if args[0] == "set" {
+		// Values of an input repeated several times are choices
+		values := make(map[string][]string)
+		for _, arg := range cmdOptions.Args() {
+			sep := strings.Index(arg, "=")
+			if sep <= 0 {
+				return fmt.Errorf("invalid input %s, expecting INPUT=VALUE", arg)
+			}
+			values[arg[:sep]] = append(values[arg[:sep]], arg[sep+1:])
+		}
+		for input, inputValues := range values {
+			defaults[input] = inputValues
+		}
+	} else {
+		for _, input := range cmdOptions.Args() {
+			if _, ok := defaults[input]; !ok {
+				return fmt.Errorf("input %s has no default", input)
+			}
+			delete(defaults, input)
+		}
+	}
+	err = client.SetEndpointDefaults(ctx, *nsID, epID, defaults)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Defaults updated for endpoint %s\n", epID)
+	return nil
+}
+
+func handleUser(ctx context.Context, client *terraApi.Client, printer *terraOutput.Printer, args []string) error {
 	var err error
 
 	switch args[0] {
 	case "list":
-		err = terraApi.ListUsers(options)
+		cmdOptions := flag.NewFlagSet("list options", flag.ExitOnError)
+		addListFlags(cmdOptions, printer)
+		cmdOptions.Parse(args[1:])
+		err = client.ListUsers(ctx, printer)
 		break
 	case "show":
 		if len(args) == 1 {
 			return fmt.Errorf("missing user id")
 		}
-		err = terraApi.ShowUser(options, args[1])
+		err = client.ShowUser(ctx, printer, args[1])
 		break
 	case "password":
 		if len(args) != 3 {
 			return fmt.Errorf("missing user id or password, usage: goterra user password USERID NEWPASSWORD")
 		}
-		err = terraApi.SetUserPassword(options, args[1], args[2])
-		if err != nil {
+		err = client.SetUserPassword(ctx, args[1], args[2])
+		if err == nil {
 			fmt.Printf("Password updated for user %s\n", args[1])
 		}
 		break
@@ -177,7 +1013,7 @@ func handleUser(options terraApi.OptionsDef, args []string) error {
 			SuperUser: *userSuper,
 			Kind:      *userKind,
 		}
-		err := terraApi.CreateUser(options, newUser)
+		err = client.CreateUser(ctx, newUser)
 		if err == nil {
 			fmt.Printf("User %s created\n", *userID)
 		}
@@ -187,87 +1023,354 @@ func handleUser(options terraApi.OptionsDef, args []string) error {
 	return err
 }
 
-func handleRecipe(options terraApi.OptionsDef, args []string) error {
+func handleRecipe(ctx context.Context, client *terraApi.Client, printer *terraOutput.Printer, args []string) error {
 	var err error
 
 	switch args[0] {
 	case "list":
 		cmdOptions := flag.NewFlagSet("list options", flag.ExitOnError)
-		nsID := cmdOptions.String("ns", "", "namespace id")
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+		addListFlags(cmdOptions, printer)
 		cmdOptions.Parse(args[1:])
-		err = terraApi.ListRecipes(options, *nsID)
+		err = client.ListRecipes(ctx, printer, *nsID)
 		break
 	case "show":
 		cmdOptions := flag.NewFlagSet("list options", flag.ExitOnError)
-		nsID := cmdOptions.String("ns", "", "namespace id")
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
 		recipeID := cmdOptions.String("id", "", "recipe id")
 		cmdOptions.Parse(args[1:])
 		if *nsID == "" && *recipeID == "" {
 			return fmt.Errorf("missing recipe or namespace id")
 		}
-		err = terraApi.ShowRecipe(options, *nsID, *recipeID)
+		err = client.ShowRecipe(ctx, printer, *nsID, *recipeID)
+		break
+	case "create", "update":
+		cmdOptions := flag.NewFlagSet(args[0]+" options", flag.ExitOnError)
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+		definition := cmdOptions.String("f", "", "recipe definition file (yaml)")
+		script := cmdOptions.String("script", "", "recipe script file, replaces script of definition")
+		recipeID := ""
+		options := args[1:]
+		if args[0] == "update" {
+			recipeID, options = subCommandArg(args)
+		}
+		cmdOptions.Parse(options)
+		if *nsID == "" || *definition == "" || (args[0] == "update" && recipeID == "") {
+			fmt.Printf("Usage: goterra recipe %s -f recipe.yaml -script install.sh\n", args[0])
+			cmdOptions.PrintDefaults()
+			return fmt.Errorf("missing namespace id, recipe id or definition file")
+		}
+		recipe, loadErr := loadRecipe(*definition, *script)
+		if loadErr != nil {
+			return loadErr
+		}
+		recipe.Namespace = *nsID
+		if args[0] == "create" {
+			recipeID, err = client.CreateRecipe(ctx, *nsID, recipe)
+		} else {
+			id, idErr := primitive.ObjectIDFromHex(recipeID)
+			if idErr != nil {
+				return fmt.Errorf("invalid recipe id %s", recipeID)
+			}
+			recipe.ID = id
+			recipeID, err = client.UpdateRecipe(ctx, *nsID, recipe)
+		}
+		if err != nil {
+			return err
+		}
+		saved, getErr := client.GetRecipe(ctx, *nsID, recipeID)
+		if getErr != nil {
+			return getErr
+		}
+		fmt.Printf("Recipe %s %sd, id: %s, version: %s\n", saved.Name, args[0], recipeID, saved.Version)
+		break
+	case "delete":
+		cmdOptions := flag.NewFlagSet("delete options", flag.ExitOnError)
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+		recipeID, options := subCommandArg(args)
+		cmdOptions.Parse(options)
+		if recipeID == "" || *nsID == "" {
+			return fmt.Errorf("missing recipe or namespace id")
+		}
+		confirm := promptConfirm("Please confirm deletion")
+		if confirm {
+			err = client.DeleteRecipe(ctx, *nsID, recipeID)
+		}
+		break
+	case "search":
+		cmdOptions := flag.NewFlagSet("search options", flag.ExitOnError)
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id, public recipes are always searched")
+		all := cmdOptions.Bool("all", false, "search recipes of all my namespaces")
+		addListFlags(cmdOptions, printer)
+		term, options := subCommandArg(args)
+		cmdOptions.Parse(options)
+		if term == "" {
+			return fmt.Errorf("missing search term")
+		}
+		var nsIDs []string
+		if *all {
+			namespaces, nsErr := client.GetNamespaces(ctx, false)
+			if nsErr != nil {
+				return nsErr
+			}
+			for _, ns := range namespaces {
+				nsIDs = append(nsIDs, ns.ID.Hex())
+			}
+		} else if *nsID != "" {
+			nsIDs = append(nsIDs, *nsID)
+		}
+		err = client.ListRecipeSearch(ctx, printer, term, nsIDs)
+		break
+	case "lint":
+		cmdOptions := flag.NewFlagSet("lint options", flag.ExitOnError)
+		definition := cmdOptions.String("f", "", "recipe definition file (yaml)")
+		script := cmdOptions.String("script", "", "recipe script file, replaces script of definition")
+		cmdOptions.Parse(args[1:])
+		if *definition == "" {
+			fmt.Println("Usage: goterra recipe lint -f recipe.yaml -script install.sh")
+			cmdOptions.PrintDefaults()
+			return fmt.Errorf("missing definition file")
+		}
+		err = lintRecipe(*definition, *script)
+		break
+	case "tree":
+		cmdOptions := flag.NewFlagSet("tree options", flag.ExitOnError)
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+		format := cmdOptions.String("format", terraOutput.TreeText, "tree format: text or dot (Graphviz)")
+		recipeID, options := subCommandArg(args)
+		cmdOptions.Parse(options)
+		if recipeID == "" || *nsID == "" {
+			return fmt.Errorf("missing recipe or namespace id")
+		}
+		tree, treeErr := client.RecipeTree(ctx, *nsID, recipeID)
+		if treeErr != nil {
+			return treeErr
+		}
+		err = terraOutput.PrintTree(os.Stdout, tree, *format)
+		break
+	case "pull":
+		cmdOptions := flag.NewFlagSet("pull options", flag.ExitOnError)
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+		dir := cmdOptions.String("d", "", "directory to write recipe to, parent recipes are written in sub directories")
+		recipeID, options := subCommandArg(args)
+		cmdOptions.Parse(options)
+		if recipeID == "" || *nsID == "" || *dir == "" {
+			fmt.Println("Usage: goterra recipe pull ID -ns NSID -d DIR")
+			cmdOptions.PrintDefaults()
+			return fmt.Errorf("missing recipe id, namespace id or directory")
+		}
+		chain, chainErr := client.GetRecipeChain(ctx, *nsID, recipeID)
+		if chainErr != nil {
+			return chainErr
+		}
+		recipeDir := *dir
+		for _, recipe := range chain {
+			if writeErr := terraSpec.WriteRecipe(recipeDir, recipe); writeErr != nil {
+				return writeErr
+			}
+			fmt.Printf("Recipe %s (%s) written to %s\n", recipe.Name, recipe.ID.Hex(), recipeDir)
+			recipeDir = filepath.Join(recipeDir, terraSpec.ParentDir)
+		}
 		break
 	}
 	return err
 }
 
-func handleTemplate(options terraApi.OptionsDef, args []string) error {
+// lintRecipe checks a recipe definition and script without server, problems are displayed prefixed by file name
+func lintRecipe(definition string, scriptFile string) error {
+	var recipe terraModel.Recipe
+	if loadErr := terraSpec.Load(definition, &recipe); loadErr != nil {
+		return loadErr
+	}
+	scriptName := definition + " script"
+	if scriptFile != "" {
+		script, readErr := ioutil.ReadFile(scriptFile)
+		if readErr != nil {
+			return readErr
+		}
+		recipe.Script = string(script)
+		scriptName = scriptFile
+	}
+	problems := terraSpec.LintRecipe(recipe)
+	errorCount := 0
+	for _, problem := range problems {
+		if problem.Line > 0 {
+			fmt.Printf("%s:%s\n", scriptName, problem)
+		} else {
+			fmt.Printf("%s: %s\n", definition, problem)
+		}
+		if problem.Level == terraSpec.LintError {
+			errorCount++
+		}
+	}
+	if errorCount > 0 {
+		return fmt.Errorf("recipe lint: %d error(s), %d warning(s)", errorCount, len(problems)-errorCount)
+	}
+	fmt.Printf("%s: ok, %d warning(s)\n", definition, len(problems))
+	return nil
+}
+
+// loadRecipe reads and validates a recipe definition, with its script from scriptFile if set
+func loadRecipe(definition string, scriptFile string) (*terraModel.Recipe, error) {
+	var recipe terraModel.Recipe
+	if loadErr := terraSpec.Load(definition, &recipe); loadErr != nil {
+		return nil, loadErr
+	}
+	if scriptFile != "" {
+		script, readErr := ioutil.ReadFile(scriptFile)
+		if readErr != nil {
+			return nil, readErr
+		}
+		recipe.Script = string(script)
+	}
+	if invalidErr := terraSpec.Invalid("recipe", terraSpec.ValidateRecipe(recipe)); invalidErr != nil {
+		return nil, invalidErr
+	}
+	return &recipe, nil
+}
+
+func handleTemplate(ctx context.Context, client *terraApi.Client, printer *terraOutput.Printer, args []string) error {
 	var err error
 
 	switch args[0] {
 	case "list":
 		cmdOptions := flag.NewFlagSet("list options", flag.ExitOnError)
-		nsID := cmdOptions.String("ns", "", "namespace id")
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+		addListFlags(cmdOptions, printer)
 		cmdOptions.Parse(args[1:])
-		err = terraApi.ListTemplates(options, *nsID)
+		err = client.ListTemplates(ctx, printer, *nsID)
 		break
 	case "show":
 		cmdOptions := flag.NewFlagSet("list options", flag.ExitOnError)
-		nsID := cmdOptions.String("ns", "", "namespace id")
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
 		templateID := cmdOptions.String("id", "", "template id")
 		cmdOptions.Parse(args[1:])
 
 		if *nsID == "" && *templateID == "" {
 			return fmt.Errorf("missing template or namespace id")
 		}
-		err = terraApi.ShowTemplate(options, *nsID, *templateID)
+		err = client.ShowTemplate(ctx, printer, *nsID, *templateID)
+		break
+	case "create", "update":
+		cmdOptions := flag.NewFlagSet(args[0]+" options", flag.ExitOnError)
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+		definition := cmdOptions.String("f", "", "template definition file (yaml)")
+		var tfDirs []string
+		cmdOptions.Var((*stringList)(&tfDirs), "tf-dir", "terraform files directory for an endpoint kind, named after kind or KIND=DIR, repeatable")
+		templateID := ""
+		options := args[1:]
+		if args[0] == "update" {
+			templateID, options = subCommandArg(args)
+		}
+		cmdOptions.Parse(options)
+		if *nsID == "" || *definition == "" || (args[0] == "update" && templateID == "") {
+			fmt.Printf("Usage: goterra template %s -f template.yaml -tf-dir ./openstack\n", args[0])
+			cmdOptions.PrintDefaults()
+			return fmt.Errorf("missing namespace id, template id or definition file")
+		}
+		template, loadErr := loadTemplate(*definition, tfDirs)
+		if loadErr != nil {
+			return loadErr
+		}
+		template.Namespace = *nsID
+		if args[0] == "create" {
+			templateID, err = client.CreateTemplate(ctx, *nsID, template)
+		} else {
+			id, idErr := primitive.ObjectIDFromHex(templateID)
+			if idErr != nil {
+				return fmt.Errorf("invalid template id %s", templateID)
+			}
+			template.ID = id
+			templateID, err = client.UpdateTemplate(ctx, *nsID, template)
+		}
+		if err != nil {
+			return err
+		}
+		saved, getErr := client.GetTemplate(ctx, *nsID, templateID)
+		if getErr != nil {
+			return getErr
+		}
+		fmt.Printf("Template %s %sd, id: %s, version: %s\n", saved.Name, args[0], templateID, saved.Version)
 		break
 	}
 	return err
 }
 
-func handleApp(options terraApi.OptionsDef, args []string) error {
+// loadTemplate reads and validates a template definition, with data and inputs from terraform directories
+//
+// Directories are named after endpoint kind, or given as KIND=DIR
+func loadTemplate(definition string, tfDirs []string) (*terraModel.Template, error) {
+	var template terraModel.Template
+	if loadErr := terraSpec.Load(definition, &template); loadErr != nil {
+		return nil, loadErr
+	}
+	for _, tfDir := range tfDirs {
+		kind, dir := filepath.Base(filepath.Clean(tfDir)), tfDir
+		if parts := strings.SplitN(tfDir, "=", 2); len(parts) == 2 {
+			kind, dir = parts[0], parts[1]
+		}
+		added, addErr := terraSpec.AddTerraformDir(&template, kind, dir)
+		if addErr != nil {
+			return nil, addErr
+		}
+		if len(added) > 0 {
+			fmt.Printf("Inputs added from %s variables: %s\n", kind, strings.Join(added, ", "))
+		}
+	}
+	if invalidErr := terraSpec.Invalid("template", terraSpec.ValidateTemplate(template)); invalidErr != nil {
+		return nil, invalidErr
+	}
+	return &template, nil
+}
+
+func handleApp(ctx context.Context, client *terraApi.Client, printer *terraOutput.Printer, args []string) error {
 	var err error
 
 	switch args[0] {
 	case "list":
 		cmdOptions := flag.NewFlagSet("list options", flag.ExitOnError)
-		nsID := cmdOptions.String("ns", "", "namespace id")
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+		addListFlags(cmdOptions, printer)
 		cmdOptions.Parse(args[1:])
-		err = terraApi.ListApps(options, *nsID)
+		err = client.ListApps(ctx, printer, *nsID)
 		break
 	case "show":
 		cmdOptions := flag.NewFlagSet("list options", flag.ExitOnError)
-		nsID := cmdOptions.String("ns", "", "namespace id")
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
 		appID := cmdOptions.String("id", "", "application id")
 		cmdOptions.Parse(args[1:])
 
 		if *nsID == "" && *appID == "" {
 			return fmt.Errorf("missing app or namespace id")
 		}
-		err = terraApi.ShowApp(options, *nsID, *appID)
+		err = client.ShowApp(ctx, printer, *nsID, *appID)
+		break
+	case "tree":
+		cmdOptions := flag.NewFlagSet("tree options", flag.ExitOnError)
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+		format := cmdOptions.String("format", terraOutput.TreeText, "tree format: text or dot (Graphviz)")
+		appID, options := subCommandArg(args)
+		cmdOptions.Parse(options)
+		if appID == "" || *nsID == "" {
+			return fmt.Errorf("missing app or namespace id")
+		}
+		tree, treeErr := client.AppTree(ctx, *nsID, appID)
+		if treeErr != nil {
+			return treeErr
+		}
+		err = terraOutput.PrintTree(os.Stdout, tree, *format)
 		break
 	}
 	return err
 }
 
-func handleRun(options terraApi.OptionsDef, args []string) error {
+func handleRun(ctx context.Context, client *terraApi.Client, printer *terraOutput.Printer, args []string) error {
 	var err error
 
 	switch args[0] {
 	case "start":
 		cmdOptions := flag.NewFlagSet("start options", flag.ExitOnError)
-		nsID := cmdOptions.String("ns", "", "namespace id")
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
 		endpointID := cmdOptions.String("endpoint", "", "endpoint id")
 		appID := cmdOptions.String("app", "", "application id")
 		name := cmdOptions.String("name", "", "name of the run")
@@ -279,20 +1382,21 @@ func handleRun(options terraApi.OptionsDef, args []string) error {
 
 		}
 		var runID string
-		runID, err = terraApi.StartRun(options, *name, *nsID, *endpointID, *appID, *params, *template)
+		runID, err = client.StartRun(ctx, *name, *nsID, *endpointID, *appID, *params, *template)
 		if runID != "" {
 			fmt.Printf("New run started, id: %s\n", runID)
 		}
 		break
 	case "list":
 		cmdOptions := flag.NewFlagSet("list options", flag.ExitOnError)
-		nsID := cmdOptions.String("ns", "", "namespace id")
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
+		addListFlags(cmdOptions, printer)
 		cmdOptions.Parse(args[1:])
-		err = terraApi.ListRuns(options, *nsID)
+		err = client.ListRuns(ctx, printer, *nsID)
 		break
 	case "show":
 		cmdOptions := flag.NewFlagSet("list options", flag.ExitOnError)
-		nsID := cmdOptions.String("ns", "", "namespace id")
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
 		runID := cmdOptions.String("id", "", "run id")
 		store := cmdOptions.Bool("store", false, "show store details (if deployed)")
 		cmdOptions.Parse(args[1:])
@@ -300,11 +1404,11 @@ func handleRun(options terraApi.OptionsDef, args []string) error {
 		if *nsID == "" && *runID == "" {
 			return fmt.Errorf("missing run or namespace id")
 		}
-		err = terraApi.ShowRun(options, *nsID, *runID, *store)
+		err = client.ShowRun(ctx, printer, *nsID, *runID, *store)
 		break
 	case "delete":
 		cmdOptions := flag.NewFlagSet("list options", flag.ExitOnError)
-		nsID := cmdOptions.String("ns", "", "namespace id")
+		nsID := cmdOptions.String("ns", defaultNamespace, "namespace id")
 		runID := cmdOptions.String("id", "", "run id")
 		cmdOptions.Parse(args[1:])
 		if *nsID == "" && *runID == "" {
@@ -312,7 +1416,7 @@ func handleRun(options terraApi.OptionsDef, args []string) error {
 		}
 		confirm := promptConfirm("Please confirm deletion")
 		if confirm {
-			terraApi.DeleteRun(options, *nsID, *runID)
+			err = client.DeleteRun(ctx, *nsID, *runID)
 		}
 		break
 	}
@@ -324,6 +1428,7 @@ type nsData terraModel.NSData
 func cliUsage() {
 	flag.PrintDefaults()
 	fmt.Printf("Subcommands:\n")
+	fmt.Printf(" * auth\n")
 	fmt.Printf(" * namespace\n")
 	fmt.Printf(" * endpoint\n")
 	fmt.Printf(" * recipe\n")
@@ -333,18 +1438,49 @@ func cliUsage() {
 	fmt.Printf(" * run\n")
 }
 
+func authUsage() {
+	fmt.Println("Auth sub commands:")
+	fmt.Println(" * login: ask for api key and save it in profile (-profile option)")
+	fmt.Println(" * whoami: show authenticated user and its namespaces")
+	fmt.Println(" * status: show server, profile, token expiry and reachability")
+	fmt.Println(" * logout: remove cached token, see -h")
+}
+
 func nsUsage() {
 	fmt.Println("Namespace sub commands:")
 	fmt.Println(" * list: list user namespaces")
 	fmt.Println(" * show NSID: show namespace NSID in details")
 	fmt.Println(" * edit NSID: update namespace NSID info, see -h")
-	fmt.Println(" * create NSNAME: creates a new user namespace")
+	fmt.Println(" * create NSNAME: creates a new user namespace, see -h")
 	fmt.Println(" * delete NSID: removes namespace")
+	fmt.Println(" * report [NSID]: usage report of namespace, or of user namespaces, see -h")
+	fmt.Println(" * export NSID -f bundle.tar.gz: export namespace endpoints, recipes, templates and applications")
+	fmt.Println(" * import -f bundle.tar.gz: import an exported namespace, see -h")
 }
 
 func endpointUsage() {
 	fmt.Println("Endpoint sub commands:")
 	fmt.Println(" * list: list endpoints")
+	fmt.Println(" * show: show endpoint, see -h")
+	fmt.Println(" * create -f endpoint.yaml: create an endpoint in namespace, see -h")
+	fmt.Println(" * update ID -f endpoint.yaml: replace endpoint definition, see -h")
+	fmt.Println(" * delete ID: remove endpoint, see -h")
+	fmt.Println(" * secret set|status|delete ID: manage your endpoint secret")
+	fmt.Println(" * defaults show|set|unset ID: manage endpoint default inputs")
+}
+
+func endpointDefaultsUsage() {
+	fmt.Println("Endpoint defaults sub commands:")
+	fmt.Println(" * show ENDPOINTID: show endpoint default inputs")
+	fmt.Println(" * set ENDPOINTID INPUT=VALUE...: set input default value, an input set several times gets a choice list")
+	fmt.Println(" * unset ENDPOINTID INPUT...: remove input defaults")
+}
+
+func endpointSecretUsage() {
+	fmt.Println("Endpoint secret sub commands:")
+	fmt.Println(" * set ENDPOINTID: set your secret (password) for endpoint, from a prompt, -stdin or -file, see -h")
+	fmt.Println(" * status ENDPOINTID: check if you have a secret for endpoint")
+	fmt.Println(" * delete ENDPOINTID: remove your secret for endpoint")
 }
 
 func userUsage() {
@@ -358,18 +1494,28 @@ func recipeUsage() {
 	fmt.Println("User sub commands:")
 	fmt.Println(" * list: list recipes")
 	fmt.Println(" * show ID: show recipe info ")
+	fmt.Println(" * search TERM: search public and namespace recipes by name, description and tags, see -h")
+	fmt.Println(" * create -f recipe.yaml -script install.sh: create a recipe, see -h")
+	fmt.Println(" * update ID -f recipe.yaml -script install.sh: update a recipe, see -h")
+	fmt.Println(" * delete ID: remove recipe, see -h")
+	fmt.Println(" * pull ID -d DIR: write recipe, and its parents, definition and script in DIR")
+	fmt.Println(" * tree ID: show recipe and its parents, see -h")
+	fmt.Println(" * lint -f recipe.yaml -script install.sh: check recipe definition and script, without server")
 }
 
 func templateUsage() {
 	fmt.Println("User sub commands:")
 	fmt.Println(" * list: list templates")
 	fmt.Println(" * show ID: show template info ")
+	fmt.Println(" * create -f template.yaml -tf-dir ./openstack: create a template from terraform files, see -h")
+	fmt.Println(" * update ID -f template.yaml -tf-dir ./openstack: update a template, see -h")
 }
 
 func appUsage() {
 	fmt.Println("User sub commands:")
 	fmt.Println(" * list: list applications")
 	fmt.Println(" * show ID: show application info ")
+	fmt.Println(" * tree ID: show application template and recipes, see -h")
 }
 
 func runUsage() {
@@ -380,6 +1526,47 @@ func runUsage() {
 	fmt.Println(" * delete ID: ask to stop run ")
 }
 
+// inList checks if value is in list
+func inList(list []string, value string) bool {
+	for _, elt := range list {
+		if elt == value {
+			return true
+		}
+	}
+	return false
+}
+
+// subCommandArg splits sub command args in a positional argument, empty if not set, and options
+func subCommandArg(args []string) (string, []string) {
+	if len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+		return args[1], args[2:]
+	}
+	return "", args[1:]
+}
+
+// parseDate parses a date as 2006-01-02, 2006-01-02 15:04:05 (local time) or RFC3339, empty value gives zero time
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04:05", time.RFC3339} {
+		if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %s, expecting 2006-01-02, 2006-01-02 15:04:05 or RFC3339", value)
+}
+
+// firstNonEmpty returns the first non empty value
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
 func promptConfirm(question string) bool {
 	fmt.Print(question + "[y/n]:")
 	var input string
@@ -392,20 +1579,21 @@ func promptConfirm(question string) bool {
 
 func main() {
 
-	options := terraApi.OptionsDef{
-		APIKEY: os.Getenv("GOT_APIKEY"),
-		URL:    os.Getenv("GOT_URL"),
-	}
-
-	// mainOptions := []string{"namespace", "run"}
-
 	var apiKey string
 	var url string
+	var profileName string
 	var showVersion bool
+	var timeout time.Duration
+	var retries int
+	var outputFormat string
 
-	flag.StringVar(&apiKey, "apikey", "", "Authentication API Key")
-	flag.StringVar(&url, "url", "https://goterra.genouest.org", "URL to Goterra host")
+	flag.StringVar(&apiKey, "apikey", "", "Authentication API Key [env GOT_APIKEY]")
+	flag.StringVar(&url, "url", "", fmt.Sprintf("URL to Goterra host [env GOT_URL, default %s]", defaultURL))
+	flag.StringVar(&profileName, "profile", "", "configuration profile to use [env GOT_PROFILE]")
 	flag.BoolVar(&showVersion, "version", false, "show client version")
+	flag.DurationVar(&timeout, "timeout", 0, "maximum duration of the command (e.g. 30s, 5m), no limit if 0")
+	flag.IntVar(&retries, "retries", -1, "max attempts for idempotent requests, 1 disables retries [env GOT_RETRIES, default 3]")
+	flag.StringVar(&outputFormat, "o", "", fmt.Sprintf("output format: %s [env GOT_OUTPUT, default table for lists, yaml otherwise]", strings.Join(terraOutput.Formats, ", ")))
 	flag.Usage = cliUsage
 	flag.Parse()
 
@@ -414,18 +1602,41 @@ func main() {
 		os.Exit(0)
 	}
 
-	if apiKey != "" {
-		options.APIKEY = apiKey
+	// Settings precedence: flags > environment > profile
+	if profileName == "" {
+		profileName = os.Getenv("GOT_PROFILE")
 	}
-	if url != "" {
-		options.URL = url
+	config, configErr := terraConfig.Load(terraConfig.DefaultPath())
+	if configErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", configErr)
+		os.Exit(1)
 	}
-
-	if options.URL == "" || options.APIKEY == "" {
-		fmt.Println("apikey and url options must not be empty")
+	profile, profileErr := config.Profile(profileName)
+	if profileErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", profileErr)
 		os.Exit(1)
 	}
 
+	options := terraApi.OptionsDef{
+		APIKEY: firstNonEmpty(apiKey, os.Getenv("GOT_APIKEY")),
+		URL:    firstNonEmpty(url, os.Getenv("GOT_URL"), profile.URL, defaultURL),
+	}
+	if options.APIKEY == "" {
+		profileAPIKey, apiKeyErr := profile.ResolveAPIKey()
+		if apiKeyErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", apiKeyErr)
+			os.Exit(1)
+		}
+		options.APIKEY = profileAPIKey
+	}
+	defaultNamespace = firstNonEmpty(os.Getenv("GOT_NAMESPACE"), profile.Namespace)
+
+	printer, printerErr := terraOutput.NewPrinter(firstNonEmpty(outputFormat, os.Getenv("GOT_OUTPUT"), profile.Output), os.Stdout)
+	if printerErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", printerErr)
+		os.Exit(2)
+	}
+
 	args := flag.Args()
 
 	if len(args) == 0 {
@@ -433,74 +1644,143 @@ func main() {
 		os.Exit(1)
 	}
 
-	token, loginErr := terraApi.Login(options.APIKEY, options.URL)
-	if loginErr != nil {
-		fmt.Printf("Error: %s\n", loginErr)
+	// Some auth commands do not need an api key
+	noAPIKey := args[0] == "auth" && len(args) > 1 && (args[1] == "login" || args[1] == "logout" || args[1] == "status")
+	// Some commands do not use the server at all
+	offline := args[0] == "recipe" && len(args) > 1 && args[1] == "lint"
+
+	if !offline && (options.URL == "" || (options.APIKEY == "" && !noAPIKey)) {
+		fmt.Println("apikey and url options must not be empty")
 		os.Exit(1)
 	}
-	options.Token = token
-	// fmt.Printf("token %s", token)
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+
+	// Cancel in-flight requests on Ctrl-C, a second one kills the process
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			fmt.Fprintln(os.Stderr, "Interrupted, cancelling...")
+			signal.Stop(interrupt)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	client := terraApi.NewClient(options)
+	client.UserAgent = fmt.Sprintf("%s/%s", terraApi.DefaultUserAgent, Version)
+	if retries < 0 && os.Getenv("GOT_RETRIES") != "" {
+		envRetries, retriesErr := strconv.Atoi(os.Getenv("GOT_RETRIES"))
+		if retriesErr != nil {
+			fmt.Printf("invalid GOT_RETRIES value: %s\n", os.Getenv("GOT_RETRIES"))
+			os.Exit(2)
+		}
+		retries = envRetries
+	}
+	if retries >= 0 {
+		client.Retry.MaxAttempts = retries
+	}
+
+	tokenCache := terraConfig.NewTokenCache(terraConfig.DefaultTokenCachePath())
+	tokenKey := terraConfig.TokenKey(firstNonEmpty(profileName, config.DefaultProfile), options.URL, options.APIKEY)
+
+	if args[0] != "auth" && !offline {
+		loginErr := login(ctx, client, tokenCache, tokenKey)
+		if loginErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", loginErr)
+			os.Exit(exitCode(loginErr))
+		}
+	}
+	loginToken := client.CurrentToken()
 
 	var err error
 
 	switch args[0] {
+	case "auth":
+		if len(args) == 1 {
+			authUsage()
+			os.Exit(1)
+		}
+		settings := &authSettings{
+			config:      config,
+			configPath:  terraConfig.DefaultPath(),
+			profileName: profileName,
+			tokenCache:  tokenCache,
+			tokenKey:    tokenKey,
+		}
+		err = handleAuth(ctx, client, settings, args[1:])
+		break
 	case "namespace":
 		if len(args) == 1 {
 			nsUsage()
 			os.Exit(1)
 		}
-		err = handleNamespace(options, args[1:])
+		err = handleNamespace(ctx, client, printer, args[1:])
 		break
 	case "endpoint":
 		if len(args) == 1 {
 			endpointUsage()
 			os.Exit(1)
 		}
-		err = handleEndpoint(options, args[1:])
+		err = handleEndpoint(ctx, client, printer, args[1:])
 		break
 	case "user":
 		if len(args) == 1 {
 			userUsage()
 			os.Exit(1)
 		}
-		err = handleUser(options, args[1:])
+		err = handleUser(ctx, client, printer, args[1:])
 		break
 	case "recipe":
 		if len(args) == 1 {
 			recipeUsage()
 			os.Exit(1)
 		}
-		err = handleRecipe(options, args[1:])
+		err = handleRecipe(ctx, client, printer, args[1:])
 		break
 	case "template":
 		if len(args) == 1 {
 			templateUsage()
 			os.Exit(1)
 		}
-		err = handleTemplate(options, args[1:])
+		err = handleTemplate(ctx, client, printer, args[1:])
 		break
 	case "app":
 		if len(args) == 1 {
 			appUsage()
 			os.Exit(1)
 		}
-		err = handleApp(options, args[1:])
+		err = handleApp(ctx, client, printer, args[1:])
 		break
 	case "run":
 		if len(args) == 1 {
 			runUsage()
 			os.Exit(1)
 		}
-		err = handleRun(options, args[1:])
+		err = handleRun(ctx, client, printer, args[1:])
 		break
 	default:
 		fmt.Printf("Unknown command: %s\n", os.Args[1])
 		os.Exit(1)
 	}
 
+	if token := client.CurrentToken(); token != loginToken && token != "" {
+		// Token was renewed during command
+		cacheToken(client, tokenCache, tokenKey)
+	}
+
 	if err != nil {
-		fmt.Printf("%s\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(exitCode(err))
 	}
 	//jsonOut, _ := json.MarshalIndent(result, "", "\t")
 
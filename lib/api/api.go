@@ -2,18 +2,19 @@ package goterraapi
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
-	"text/tabwriter"
-	"time"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"gopkg.in/yaml.v2"
 
+	terraOutput "github.com/osallou/goterra-cli/lib/output"
 	terraModel "github.com/osallou/goterra-lib/lib/model"
 	terraUser "github.com/osallou/goterra-lib/lib/user"
 )
@@ -37,146 +38,76 @@ type OptionsDef struct {
 }
 
 // CreateUser creates a new user
-func CreateUser(options OptionsDef, user *terraUser.User) error {
-	data, dataErr := json.Marshal(user)
-	if dataErr != nil {
-		return dataErr
-	}
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("POST", fmt.Sprintf("%s/auth/register", options.URL), bytes.NewBuffer(data))
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-	if authReqErr != nil {
-		return authReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return authReqErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return fmt.Errorf("Failed to create user: %s", data["message"].(string))
-	}
-	return nil
+func (c *Client) CreateUser(ctx context.Context, user *terraUser.User) error {
+	return c.call(ctx, "POST", "/auth/register", user, http.StatusOK, nil, "Failed to create user")
 }
 
-// Login authenticate users and return a token
-func Login(apiKey string, url string) (string, error) {
-	client := http.Client{}
-	authReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/auth/api", url), nil)
-	authReq.Header.Set("X-API-Key", apiKey)
+// Authenticate exchanges client api key for a token and returns it with user data
+func (c *Client) Authenticate(ctx context.Context) (*AuthData, error) {
+	authReq, authReqErr := c.newRequest(ctx, "GET", "/auth/api", nil)
 	if authReqErr != nil {
-		return "", authReqErr
-
-	}
-	authResp, authRespErr := client.Do(authReq)
-	if authRespErr != nil {
-		return "", authReqErr
-
+		return nil, authReqErr
 	}
-	defer authResp.Body.Close()
-	if authResp.StatusCode != 200 {
-		return "", fmt.Errorf("Failed to authenticate")
+	authReq.Header.Del("Authorization")
+	authReq.Header.Set("X-API-Key", c.APIKey)
+	var authData AuthData
+	authErr := c.do(authReq, http.StatusOK, &authData, "Failed to authenticate")
+	if authErr != nil {
+		return nil, authErr
 	}
+	return &authData, nil
+}
 
-	var authData AuthData
-	json.NewDecoder(authResp.Body).Decode(&authData)
+// Login authenticate users with client api key and return a token
+func (c *Client) Login(ctx context.Context) (string, error) {
+	authData, authErr := c.Authenticate(ctx)
+	if authErr != nil {
+		return "", authErr
+	}
 	return authData.Token, nil
 }
 
 // GetNamespaces returns user namespaces
-func GetNamespaces(options OptionsDef, showAll bool) ([]terraModel.NSData, error) {
-	client := http.Client{}
-
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns", options.URL), nil)
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-
+func (c *Client) GetNamespaces(ctx context.Context, showAll bool) ([]terraModel.NSData, error) {
+	path := "/deploy/ns"
 	if showAll {
-		q := nsReq.URL.Query()
-		q.Add("all", "1")
-		nsReq.URL.RawQuery = q.Encode()
+		path = path + "?all=1"
 	}
-	if authReqErr != nil {
-		return nil, authReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, authReqErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get namespaces: %s", data["message"].(string))
-	}
-
 	var nsResult NSResp
-	json.NewDecoder(nsResp.Body).Decode(&nsResult)
-	data := nsResult.NS
-	return data, nil
+	err := c.call(ctx, "GET", path, nil, http.StatusOK, &nsResult, "Failed to get namespaces")
+	if err != nil {
+		return nil, err
+	}
+	return nsResult.NS, nil
 }
 
 // ListNamespaces list the user namespaces
-func ListNamespaces(options OptionsDef, showAll bool) error {
-	data, err := GetNamespaces(options, showAll)
+func (c *Client) ListNamespaces(ctx context.Context, printer *terraOutput.Printer, showAll bool) error {
+	data, err := c.GetNamespaces(ctx, showAll)
 	if err != nil {
 		return err
 	}
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, '\t', tabwriter.AlignRight|tabwriter.Debug)
-	fmt.Fprintf(w, "%s\t%s\t%s\n", "ID", "Name", "Owners")
-	for _, ns := range data {
-		fmt.Fprintf(w, "%s\t%s\t%s\n", ns.ID.Hex(), ns.Name, strings.Join(ns.Owners, ","))
-	}
-	w.Flush()
-	return nil
+	return printer.PrintList(terraOutput.Namespaces, data)
 }
 
 // GetNamespace returns selected namespace
-func GetNamespace(options OptionsDef, nsID string) (*terraModel.NSData, error) {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns/%s", options.URL, nsID), nil)
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-	if authReqErr != nil {
-		return nil, authReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, authReqErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get namespace: %s", data["message"].(string))
-	}
-
+func (c *Client) GetNamespace(ctx context.Context, nsID string) (*terraModel.NSData, error) {
 	var nsResult map[string]terraModel.NSData
-	json.NewDecoder(nsResp.Body).Decode(&nsResult)
+	err := c.call(ctx, "GET", fmt.Sprintf("/deploy/ns/%s", nsID), nil, http.StatusOK, &nsResult, "Failed to get namespace")
+	if err != nil {
+		return nil, err
+	}
 	nsData := nsResult["ns"]
 	return &nsData, nil
 }
 
 // ShowNamespace displays the user namespaces
-func ShowNamespace(options OptionsDef, nsID string) error {
-
-	data, err := GetNamespace(options, nsID)
+func (c *Client) ShowNamespace(ctx context.Context, printer *terraOutput.Printer, nsID string) error {
+	data, err := c.GetNamespace(ctx, nsID)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("id: %s\n", data.ID.Hex())
-	yamlData, _ := yaml.Marshal(data)
-	fmt.Printf("%s\n", yamlData)
-	return nil
+	return printer.PrintItem(terraOutput.Namespaces, *data)
 }
 
 // AddToList adds an element to list without duplicates and returns updated list
@@ -209,617 +140,482 @@ func RemoveFromList(members []string, deprecatedMember string) []string {
 	return append(members[:index], members[index+1:]...)
 }
 
-// UpdateNamespace updates namespace data
-func UpdateNamespace(options OptionsDef, ns *terraModel.NSData) error {
-	data, dataErr := json.Marshal(ns)
-	if dataErr != nil {
-		return dataErr
-	}
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("PUT", fmt.Sprintf("%s/deploy/ns/%s", options.URL, ns.ID.Hex()), bytes.NewBuffer(data))
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-	if authReqErr != nil {
-		return authReqErr
-
+// DiffList returns the elements added to and removed from before list in after list
+func DiffList(before []string, after []string) (added []string, removed []string) {
+	for _, elt := range after {
+		if !inList(before, elt) {
+			added = append(added, elt)
+		}
 	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return authReqErr
-
+	for _, elt := range before {
+		if !inList(after, elt) {
+			removed = append(removed, elt)
+		}
 	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return fmt.Errorf("Failed to update namespace: %s", data["message"].(string))
+	return added, removed
+}
+
+func inList(list []string, value string) bool {
+	for _, elt := range list {
+		if elt == value {
+			return true
+		}
 	}
+	return false
+}
 
-	return nil
+// UpdateNamespace updates namespace data
+func (c *Client) UpdateNamespace(ctx context.Context, ns *terraModel.NSData) error {
+	return c.call(idempotent(ctx), "PUT", fmt.Sprintf("/deploy/ns/%s", ns.ID.Hex()), ns, http.StatusOK, nil, "Failed to update namespace")
 }
 
 // DeleteNamespace removes namespace
-func DeleteNamespace(options OptionsDef, nsID string) error {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("DELETE", fmt.Sprintf("%s/deploy/ns/%s", options.URL, nsID), nil)
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-	if authReqErr != nil {
-		return authReqErr
-
+func (c *Client) DeleteNamespace(ctx context.Context, nsID string) error {
+	return c.call(ctx, "DELETE", fmt.Sprintf("/deploy/ns/%s", nsID), nil, http.StatusOK, nil, "Failed to delete namespace")
+}
+
+// CreateNamespace creates a new namespace and returns it with its new id
+//
+// ns.ID is ignored
+func (c *Client) CreateNamespace(ctx context.Context, ns *terraModel.NSData) (*terraModel.NSData, error) {
+	newNS := struct {
+		Name    string   `json:"name"`
+		Owners  []string `json:"owners,omitempty"`
+		Members []string `json:"members,omitempty"`
+		Freeze  bool     `json:"freeze"`
+	}{
+		Name:    ns.Name,
+		Owners:  ns.Owners,
+		Members: ns.Members,
+		Freeze:  ns.Freeze,
+	}
+	nsID, err := c.createObject(ctx, "/deploy/ns", newNS, "ns", "Failed to create namespace")
+	if err != nil {
+		return nil, err
 	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return authReqErr
+	return c.GetNamespace(ctx, nsID)
+}
 
+// createObject posts a new object and returns its id
+//
+// Server answers with the new object id, or the object, in key field
+func (c *Client) createObject(ctx context.Context, path string, obj interface{}, key string, errMsg string) (string, error) {
+	var result map[string]json.RawMessage
+	err := c.call(ctx, "POST", path, obj, http.StatusCreated, &result, errMsg)
+	if err != nil {
+		return "", err
 	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return fmt.Errorf("Failed to delete namespace: %s", data["message"].(string))
+	id := answerID(result[key])
+	if id == "" {
+		return "", fmt.Errorf("%s: no %s id in answer", errMsg, key)
 	}
-
-	return nil
+	return id, nil
 }
 
-// CreateNamespace creates a new namespace
-func CreateNamespace(options OptionsDef, ns *terraModel.NSData) error {
-	data, dataErr := json.Marshal(ns)
-	if dataErr != nil {
-		return dataErr
+// answerID returns the object id in an answer field, which is the id or the object, empty if not found
+func answerID(field json.RawMessage) string {
+	var id string
+	if json.Unmarshal(field, &id) == nil {
+		return id
 	}
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("POST", fmt.Sprintf("%s/deploy/ns/%s", options.URL, ns.ID.Hex()), bytes.NewBuffer(data))
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-	if authReqErr != nil {
-		return authReqErr
-
+	var obj struct {
+		ID primitive.ObjectID `json:"id"`
 	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return authReqErr
-
+	if json.Unmarshal(field, &obj) != nil || obj.ID == primitive.NilObjectID {
+		return ""
 	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 201 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return fmt.Errorf("Failed to create namespace: %s", data["message"].(string))
-	}
-
-	return nil
+	return obj.ID.Hex()
 }
 
 // GetEndpoints returns endpoints for namespace or public endpoints if nsID is empty
-func GetEndpoints(options OptionsDef, nsID string) ([]terraModel.EndPoint, error) {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/endpoints", options.URL), nil)
+func (c *Client) GetEndpoints(ctx context.Context, nsID string) ([]terraModel.EndPoint, error) {
+	path := "/deploy/endpoints"
 	if nsID != "" {
-		nsReq, authReqErr = http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns/%s/endpoint", options.URL, nsID), nil)
+		path = fmt.Sprintf("/deploy/ns/%s/endpoint", nsID)
 	}
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-
-	if authReqErr != nil {
-		return nil, authReqErr
-
+	var nsResult map[string][]terraModel.EndPoint
+	err := c.call(ctx, "GET", path, nil, http.StatusOK, &nsResult, "Failed to get endpoints")
+	if err != nil {
+		return nil, err
 	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, authReqErr
+	return nsResult["endpoints"], nil
+}
 
+// GetEndpoint returns selected endpoint
+func (c *Client) GetEndpoint(ctx context.Context, nsID, epID string) (*terraModel.EndPoint, error) {
+	var nsResult map[string]terraModel.EndPoint
+	err := c.call(ctx, "GET", fmt.Sprintf("/deploy/ns/%s/endpoint/%s", nsID, epID), nil, http.StatusOK, &nsResult, "Failed to get endpoint")
+	if err != nil {
+		return nil, err
 	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get endpoints: %s", data["message"].(string))
+	nsData := nsResult["endpoint"]
+	return &nsData, nil
+}
+
+// CreateEndpoint creates an endpoint in namespace and returns its id
+func (c *Client) CreateEndpoint(ctx context.Context, nsID string, endpoint *terraModel.EndPoint) (string, error) {
+	return c.createObject(ctx, fmt.Sprintf("/deploy/ns/%s/endpoint", nsID), endpoint, "endpoint", "Failed to create endpoint")
+}
+
+// UpdateEndpoint replaces endpoint definition
+func (c *Client) UpdateEndpoint(ctx context.Context, nsID string, endpoint *terraModel.EndPoint) error {
+	return c.call(idempotent(ctx), "PUT", fmt.Sprintf("/deploy/ns/%s/endpoint/%s", nsID, endpoint.ID.Hex()), endpoint, http.StatusOK, nil, "Failed to update endpoint")
+}
+
+// DeleteEndpoint removes endpoint
+func (c *Client) DeleteEndpoint(ctx context.Context, nsID string, epID string) error {
+	return c.call(ctx, "DELETE", fmt.Sprintf("/deploy/ns/%s/endpoint/%s", nsID, epID), nil, http.StatusOK, nil, "Failed to delete endpoint")
+}
+
+// HasEndpointSecret checks if user registered a secret for endpoint
+func (c *Client) HasEndpointSecret(ctx context.Context, nsID string, epID string) (bool, error) {
+	err := c.call(ctx, "GET", fmt.Sprintf("/deploy/ns/%s/endpoint/%s/secret", nsID, epID), nil, http.StatusOK, nil, "Failed to get endpoint secret")
+	if IsNotFound(err) {
+		return false, nil
 	}
+	return err == nil, err
+}
 
-	var nsResult map[string][]terraModel.EndPoint
-	json.NewDecoder(nsResp.Body).Decode(&nsResult)
-	data := nsResult["endpoints"]
-	return data, nil
+// SetEndpointSecret registers, or replaces, user secret for endpoint
+func (c *Client) SetEndpointSecret(ctx context.Context, nsID string, epID string, secret string) error {
+	secretData := map[string]string{"secret": secret}
+	return c.call(idempotent(ctx), "PUT", fmt.Sprintf("/deploy/ns/%s/endpoint/%s/secret", nsID, epID), secretData, http.StatusOK, nil, "Failed to set endpoint secret")
 }
 
-// GetEndpoint returns selected endpoint
-func GetEndpoint(options OptionsDef, nsID, epID string) (*terraModel.EndPoint, error) {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns/%s/endpoint/%s", options.URL, nsID, epID), nil)
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-	if authReqErr != nil {
-		return nil, authReqErr
+// DeleteEndpointSecret removes user secret for endpoint
+func (c *Client) DeleteEndpointSecret(ctx context.Context, nsID string, epID string) error {
+	return c.call(ctx, "DELETE", fmt.Sprintf("/deploy/ns/%s/endpoint/%s/secret", nsID, epID), nil, http.StatusOK, nil, "Failed to delete endpoint secret")
+}
 
+// GetEndpointDefaults returns endpoint default inputs, a single value is a default value, several values are choices
+func (c *Client) GetEndpointDefaults(ctx context.Context, nsID string, epID string) (map[string][]string, error) {
+	var endpointDefaults map[string]map[string][]string
+	err := c.call(ctx, "GET", fmt.Sprintf("/deploy/ns/%s/endpoint/%s/defaults", nsID, epID), nil, http.StatusOK, &endpointDefaults, "Failed to get endpoint defaults")
+	if err != nil {
+		return nil, err
 	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, authReqErr
+	return endpointDefaults["defaults"], nil
+}
+
+// SetEndpointDefaults replaces endpoint default inputs
+func (c *Client) SetEndpointDefaults(ctx context.Context, nsID string, epID string, defaults map[string][]string) error {
+	defaultsData := map[string]map[string][]string{"defaults": defaults}
+	return c.call(idempotent(ctx), "PUT", fmt.Sprintf("/deploy/ns/%s/endpoint/%s/defaults", nsID, epID), defaultsData, http.StatusOK, nil, "Failed to set endpoint defaults")
+}
 
+// ShowEndpointDefaults displays endpoint default inputs
+func (c *Client) ShowEndpointDefaults(ctx context.Context, printer *terraOutput.Printer, nsID string, epID string) error {
+	defaults, err := c.GetEndpointDefaults(ctx, nsID, epID)
+	if err != nil {
+		return err
 	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get namespace: %s", data["message"].(string))
+	inputs := make([]string, 0, len(defaults))
+	for input := range defaults {
+		inputs = append(inputs, input)
 	}
+	sort.Strings(inputs)
+	items := make([]terraOutput.EndpointDefault, len(inputs))
+	for i, input := range inputs {
+		items[i] = terraOutput.EndpointDefault{Input: input, Values: defaults[input]}
+	}
+	return printer.PrintList(terraOutput.EndpointDefaults, items)
+}
 
-	var nsResult map[string]terraModel.EndPoint
-	json.NewDecoder(nsResp.Body).Decode(&nsResult)
-	nsData := nsResult["endpoint"]
-	return &nsData, nil
+// endpointItem adds user secret status to endpoint, status is unknown if not in namespace scope or on error
+func (c *Client) endpointItem(ctx context.Context, nsID string, endpoint terraModel.EndPoint) terraOutput.EndpointItem {
+	item := terraOutput.EndpointItem{EndPoint: endpoint}
+	if nsID == "" {
+		return item
+	}
+	if hasSecret, err := c.HasEndpointSecret(ctx, nsID, endpoint.ID.Hex()); err == nil {
+		item.HasSecret = &hasSecret
+	}
+	return item
 }
 
-// ListEndpoints list the endpoints
-func ListEndpoints(options OptionsDef, nsID string) error {
-	data, err := GetEndpoints(options, nsID)
+// ListEndpoints list the endpoints, with user secret status in namespace scope
+func (c *Client) ListEndpoints(ctx context.Context, printer *terraOutput.Printer, nsID string) error {
+	data, err := c.GetEndpoints(ctx, nsID)
 	if err != nil {
 		return err
 	}
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, '\t', tabwriter.AlignRight|tabwriter.Debug)
-	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", "ID", "Name", "Kind", "Public", "Namespace")
-	for _, ep := range data {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", ep.ID.Hex(), ep.Name, ep.Kind, ep.Public, ep.Namespace)
+	items := make([]terraOutput.EndpointItem, len(data))
+	for i, endpoint := range data {
+		items[i] = c.endpointItem(ctx, nsID, endpoint)
 	}
-	w.Flush()
-	return nil
+	return printer.PrintList(terraOutput.Endpoints, items)
 }
 
 // ShowEndpoint displays the endpoint
-func ShowEndpoint(options OptionsDef, nsID string, epID string) error {
-
-	data, err := GetEndpoint(options, nsID, epID)
+func (c *Client) ShowEndpoint(ctx context.Context, printer *terraOutput.Printer, nsID string, epID string) error {
+	data, err := c.GetEndpoint(ctx, nsID, epID)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("id: %s\n", data.ID.Hex())
-	yamlData, _ := yaml.Marshal(data)
-	fmt.Printf("%s\n", yamlData)
-
-	return nil
+	return printer.PrintItem(terraOutput.Endpoints, c.endpointItem(ctx, nsID, *data))
 }
 
 // GetUsers returns list of users [admin only]
-func GetUsers(options OptionsDef) ([]terraUser.User, error) {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/auth/user", options.URL), nil)
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-
-	if authReqErr != nil {
-		return nil, authReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, authReqErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get users: %s", data["message"].(string))
-	}
-
+func (c *Client) GetUsers(ctx context.Context) ([]terraUser.User, error) {
 	var nsResult map[string][]terraUser.User
-	json.NewDecoder(nsResp.Body).Decode(&nsResult)
-	data := nsResult["users"]
-	return data, nil
+	err := c.call(ctx, "GET", "/auth/user", nil, http.StatusOK, &nsResult, "Failed to get users")
+	if err != nil {
+		return nil, err
+	}
+	return nsResult["users"], nil
 }
 
 // ListUsers list the users
-func ListUsers(options OptionsDef) error {
-	data, err := GetUsers(options)
+func (c *Client) ListUsers(ctx context.Context, printer *terraOutput.Printer) error {
+	data, err := c.GetUsers(ctx)
 	if err != nil {
 		return err
 	}
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, '\t', tabwriter.AlignRight|tabwriter.Debug)
-	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", "UID", "Admin", "Super user", "Email", "Kind")
-	for _, user := range data {
-		fmt.Fprintf(w, "%s\t%t\t%t\t%s\t%s\n", user.UID, user.Admin, user.SuperUser, user.Email, user.Kind)
+	for i := range data {
+		data[i].Password = ""
 	}
-	w.Flush()
-	return nil
+	return printer.PrintList(terraOutput.Users, data)
 }
 
 // GetUser returns selected user
-func GetUser(options OptionsDef, userID string) (*terraUser.User, error) {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/auth/user/%s", options.URL, userID), nil)
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-	if authReqErr != nil {
-		return nil, authReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, authReqErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get namespace: %s", data["message"].(string))
-	}
-
+func (c *Client) GetUser(ctx context.Context, userID string) (*terraUser.User, error) {
 	var nsResult map[string]terraUser.User
-	json.NewDecoder(nsResp.Body).Decode(&nsResult)
+	err := c.call(ctx, "GET", fmt.Sprintf("/auth/user/%s", userID), nil, http.StatusOK, &nsResult, "Failed to get user")
+	if err != nil {
+		return nil, err
+	}
 	nsData := nsResult["user"]
 	return &nsData, nil
 }
 
 // ShowUser displays the user info
-func ShowUser(options OptionsDef, userID string) error {
-	data, err := GetUser(options, userID)
+func (c *Client) ShowUser(ctx context.Context, printer *terraOutput.Printer, userID string) error {
+	data, err := c.GetUser(ctx, userID)
 	if err != nil {
 		return err
 	}
 	data.Password = "*****"
-	yamlData, _ := yaml.Marshal(data)
-	fmt.Printf("%s\n", yamlData)
-
-	return nil
+	return printer.PrintItem(terraOutput.Users, *data)
 }
 
 // SetUserPassword modifies user password
-func SetUserPassword(options OptionsDef, userID string, password string) error {
+func (c *Client) SetUserPassword(ctx context.Context, userID string, password string) error {
 	passwordInfo := make(map[string]string)
 	passwordInfo["password"] = password
-	data, _ := json.Marshal(passwordInfo)
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("PUT", fmt.Sprintf("%s/auth/user/%s/password", options.URL, userID), bytes.NewBuffer(data))
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-	if authReqErr != nil {
-		return authReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return authReqErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return fmt.Errorf("Failed to update user password: %s", data["message"].(string))
-	}
-	return nil
+	return c.call(idempotent(ctx), "PUT", fmt.Sprintf("/auth/user/%s/password", userID), passwordInfo, http.StatusOK, nil, "Failed to update user password")
 }
 
 // GetRecipes returns recipes for namespace or public recipes if id is empty
-func GetRecipes(options OptionsDef, id string) ([]terraModel.Recipe, error) {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/recipes", options.URL), nil)
+func (c *Client) GetRecipes(ctx context.Context, id string) ([]terraModel.Recipe, error) {
+	path := "/deploy/recipes"
 	if id != "" {
-		nsReq, authReqErr = http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns/%s/endpoint", options.URL, id), nil)
-	}
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-
-	if authReqErr != nil {
-		return nil, authReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, authReqErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get endpoints: %s", data["message"].(string))
+		path = fmt.Sprintf("/deploy/ns/%s/recipe", id)
 	}
-
 	var nsResult map[string][]terraModel.Recipe
-	json.NewDecoder(nsResp.Body).Decode(&nsResult)
-	data := nsResult["recipes"]
-	return data, nil
+	err := c.call(ctx, "GET", path, nil, http.StatusOK, &nsResult, "Failed to get recipes")
+	if err != nil {
+		return nil, err
+	}
+	return nsResult["recipes"], nil
 }
 
 // GetRecipe returns selected ns
-func GetRecipe(options OptionsDef, nsID string, id string) (*terraModel.Recipe, error) {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns/%s/recipe/%s", options.URL, nsID, id), nil)
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-	if authReqErr != nil {
-		return nil, authReqErr
-
+func (c *Client) GetRecipe(ctx context.Context, nsID string, id string) (*terraModel.Recipe, error) {
+	var nsResult map[string]terraModel.Recipe
+	err := c.call(ctx, "GET", fmt.Sprintf("/deploy/ns/%s/recipe/%s", nsID, id), nil, http.StatusOK, &nsResult, "Failed to get recipe")
+	if err != nil {
+		return nil, err
 	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, authReqErr
+	nsData := nsResult["recipe"]
+	return &nsData, nil
+}
 
+// CreateRecipe creates a recipe in namespace and returns its id
+func (c *Client) CreateRecipe(ctx context.Context, nsID string, recipe *terraModel.Recipe) (string, error) {
+	return c.createObject(ctx, fmt.Sprintf("/deploy/ns/%s/recipe", nsID), recipe, "recipe", "Failed to create recipe")
+}
+
+// UpdateRecipe updates recipe and returns its id, which is a new one if server created a new recipe version
+//
+// Request is not retried as a replay could create an other version
+func (c *Client) UpdateRecipe(ctx context.Context, nsID string, recipe *terraModel.Recipe) (string, error) {
+	var result map[string]json.RawMessage
+	err := c.call(ctx, "PUT", fmt.Sprintf("/deploy/ns/%s/recipe/%s", nsID, recipe.ID.Hex()), recipe, http.StatusOK, &result, "Failed to update recipe")
+	if err != nil {
+		return "", err
 	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get namespace: %s", data["message"].(string))
+	if id := answerID(result["recipe"]); id != "" {
+		return id, nil
 	}
+	return recipe.ID.Hex(), nil
+}
 
-	var nsResult map[string]terraModel.Recipe
-	json.NewDecoder(nsResp.Body).Decode(&nsResult)
-	nsData := nsResult["recipe"]
-	return &nsData, nil
+// DeleteRecipe removes recipe
+func (c *Client) DeleteRecipe(ctx context.Context, nsID string, id string) error {
+	return c.call(ctx, "DELETE", fmt.Sprintf("/deploy/ns/%s/recipe/%s", nsID, id), nil, http.StatusOK, nil, "Failed to delete recipe")
+}
+
+// GetRecipeChain returns recipe id followed by its parent recipes, closest parent first
+func (c *Client) GetRecipeChain(ctx context.Context, nsID string, id string) ([]terraModel.Recipe, error) {
+	var chain []terraModel.Recipe
+	seen := make(map[string]bool)
+	for id != "" {
+		if seen[id] {
+			return nil, fmt.Errorf("recipe %s has a circular parent reference", id)
+		}
+		seen[id] = true
+		recipe, err := c.GetRecipe(ctx, nsID, id)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, *recipe)
+		id = recipe.ParentRecipe
+	}
+	return chain, nil
 }
 
 // ListRecipes list the recipes
-func ListRecipes(options OptionsDef, nsID string) error {
-	data, err := GetRecipes(options, nsID)
+func (c *Client) ListRecipes(ctx context.Context, printer *terraOutput.Printer, nsID string) error {
+	data, err := c.GetRecipes(ctx, nsID)
 	if err != nil {
 		return err
 	}
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, '\t', tabwriter.AlignRight|tabwriter.Debug)
-	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", "ID", "Name", "Description", "Public", "Namespace")
-	for _, ep := range data {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", ep.ID.Hex(), ep.Name, ep.Description, ep.Public, ep.Namespace)
-	}
-	w.Flush()
-	return nil
+	return printer.PrintList(terraOutput.Recipes, data)
 }
 
 // ShowRecipe displays the recipe
-func ShowRecipe(options OptionsDef, nsID string, id string) error {
-
-	data, err := GetRecipe(options, nsID, id)
-
+func (c *Client) ShowRecipe(ctx context.Context, printer *terraOutput.Printer, nsID string, id string) error {
+	data, err := c.GetRecipe(ctx, nsID, id)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("id: %s\n", data.ID.Hex())
-	yamlData, _ := yaml.Marshal(data)
-	fmt.Printf("%s\n", yamlData)
-	return nil
+	return printer.PrintItem(terraOutput.Recipes, *data)
 }
 
 // GetTemplates returns templates for namespace or public templates if id is empty
-func GetTemplates(options OptionsDef, id string) ([]terraModel.Template, error) {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/templates", options.URL), nil)
+func (c *Client) GetTemplates(ctx context.Context, id string) ([]terraModel.Template, error) {
+	path := "/deploy/templates"
 	if id != "" {
-		nsReq, authReqErr = http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns/%s/template", options.URL, id), nil)
+		path = fmt.Sprintf("/deploy/ns/%s/template", id)
 	}
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-
-	if authReqErr != nil {
-		return nil, authReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, authReqErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get templates: %s", data["message"].(string))
-	}
-
 	var nsResult map[string][]terraModel.Template
-	json.NewDecoder(nsResp.Body).Decode(&nsResult)
-	data := nsResult["templates"]
-	return data, nil
+	err := c.call(ctx, "GET", path, nil, http.StatusOK, &nsResult, "Failed to get templates")
+	if err != nil {
+		return nil, err
+	}
+	return nsResult["templates"], nil
 }
 
 // GetTemplate returns selected template
-func GetTemplate(options OptionsDef, nsID string, id string) (*terraModel.Template, error) {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns/%s/template/%s", options.URL, nsID, id), nil)
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-	if authReqErr != nil {
-		return nil, authReqErr
-
+func (c *Client) GetTemplate(ctx context.Context, nsID string, id string) (*terraModel.Template, error) {
+	var nsResult map[string]terraModel.Template
+	err := c.call(ctx, "GET", fmt.Sprintf("/deploy/ns/%s/template/%s", nsID, id), nil, http.StatusOK, &nsResult, "Failed to get template")
+	if err != nil {
+		return nil, err
 	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, authReqErr
+	nsData := nsResult["template"]
+	return &nsData, nil
+}
+
+// CreateTemplate creates a template in namespace and returns its id
+func (c *Client) CreateTemplate(ctx context.Context, nsID string, template *terraModel.Template) (string, error) {
+	return c.createObject(ctx, fmt.Sprintf("/deploy/ns/%s/template", nsID), template, "template", "Failed to create template")
+}
 
+// UpdateTemplate updates template and returns its id, which is a new one if server created a new template version
+//
+// Request is not retried as a replay could create an other version
+func (c *Client) UpdateTemplate(ctx context.Context, nsID string, template *terraModel.Template) (string, error) {
+	var result map[string]json.RawMessage
+	err := c.call(ctx, "PUT", fmt.Sprintf("/deploy/ns/%s/template/%s", nsID, template.ID.Hex()), template, http.StatusOK, &result, "Failed to update template")
+	if err != nil {
+		return "", err
 	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get namespace: %s", data["message"].(string))
+	if id := answerID(result["template"]); id != "" {
+		return id, nil
 	}
-
-	var nsResult map[string]terraModel.Template
-	json.NewDecoder(nsResp.Body).Decode(&nsResult)
-	nsData := nsResult["template"]
-	return &nsData, nil
+	return template.ID.Hex(), nil
 }
 
 // ListTemplates list the templates
-func ListTemplates(options OptionsDef, nsID string) error {
-	data, err := GetTemplates(options, nsID)
+func (c *Client) ListTemplates(ctx context.Context, printer *terraOutput.Printer, nsID string) error {
+	data, err := c.GetTemplates(ctx, nsID)
 	if err != nil {
 		return err
 	}
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, '\t', tabwriter.AlignRight|tabwriter.Debug)
-	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", "ID", "Name", "Description", "Public", "Namespace")
-	for _, ep := range data {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", ep.ID.Hex(), ep.Name, ep.Description, ep.Public, ep.Namespace)
-	}
-	w.Flush()
-	return nil
+	return printer.PrintList(terraOutput.Templates, data)
 }
 
 // ShowTemplate displays the template
-func ShowTemplate(options OptionsDef, nsID string, id string) error {
-
-	data, err := GetTemplate(options, nsID, id)
-
+func (c *Client) ShowTemplate(ctx context.Context, printer *terraOutput.Printer, nsID string, id string) error {
+	data, err := c.GetTemplate(ctx, nsID, id)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("id: %s\n", data.ID.Hex())
-	yamlData, _ := yaml.Marshal(data)
-	fmt.Printf("%s\n", yamlData)
-	return nil
+	return printer.PrintItem(terraOutput.Templates, *data)
 }
 
 // GetApps returns apps for namespace or public apps if id is empty
-func GetApps(options OptionsDef, id string) ([]terraModel.Application, error) {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/apps", options.URL), nil)
+func (c *Client) GetApps(ctx context.Context, id string) ([]terraModel.Application, error) {
+	path := "/deploy/apps"
 	if id != "" {
-		nsReq, authReqErr = http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns/%s/app", options.URL, id), nil)
-	}
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-
-	if authReqErr != nil {
-		return nil, authReqErr
-
+		path = fmt.Sprintf("/deploy/ns/%s/app", id)
 	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, authReqErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get applications: %s", data["message"].(string))
-	}
-
 	var nsResult map[string][]terraModel.Application
-	json.NewDecoder(nsResp.Body).Decode(&nsResult)
-	data := nsResult["apps"]
-	return data, nil
+	err := c.call(ctx, "GET", path, nil, http.StatusOK, &nsResult, "Failed to get applications")
+	if err != nil {
+		return nil, err
+	}
+	return nsResult["apps"], nil
 }
 
 // GetApp returns selected template
-func GetApp(options OptionsDef, nsID string, id string) (*terraModel.Application, error) {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns/%s/app/%s", options.URL, nsID, id), nil)
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-	if authReqErr != nil {
-		return nil, authReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, authReqErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get application: %s", data["message"].(string))
-	}
-
+func (c *Client) GetApp(ctx context.Context, nsID string, id string) (*terraModel.Application, error) {
 	var nsResult map[string]terraModel.Application
-	json.NewDecoder(nsResp.Body).Decode(&nsResult)
+	err := c.call(ctx, "GET", fmt.Sprintf("/deploy/ns/%s/app/%s", nsID, id), nil, http.StatusOK, &nsResult, "Failed to get application")
+	if err != nil {
+		return nil, err
+	}
 	nsData := nsResult["app"]
 	return &nsData, nil
 }
 
+// CreateApp creates an application in namespace and returns its id
+func (c *Client) CreateApp(ctx context.Context, nsID string, app *terraModel.Application) (string, error) {
+	return c.createObject(ctx, fmt.Sprintf("/deploy/ns/%s/app", nsID), app, "app", "Failed to create application")
+}
+
 // ListApps list the applications
-func ListApps(options OptionsDef, nsID string) error {
-	data, err := GetApps(options, nsID)
+func (c *Client) ListApps(ctx context.Context, printer *terraOutput.Printer, nsID string) error {
+	data, err := c.GetApps(ctx, nsID)
 	if err != nil {
 		return err
 	}
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, '\t', tabwriter.AlignRight|tabwriter.Debug)
-	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", "ID", "Name", "Description", "Public", "Namespace")
-	for _, ep := range data {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", ep.ID.Hex(), ep.Name, ep.Description, ep.Public, ep.Namespace)
-	}
-	w.Flush()
-	return nil
+	return printer.PrintList(terraOutput.Apps, data)
 }
 
 // ShowApp displays the application
-func ShowApp(options OptionsDef, nsID string, id string) error {
-
-	data, err := GetApp(options, nsID, id)
-
+func (c *Client) ShowApp(ctx context.Context, printer *terraOutput.Printer, nsID string, id string) error {
+	data, err := c.GetApp(ctx, nsID, id)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("id: %s\n", data.ID.Hex())
-	yamlData, _ := yaml.Marshal(data)
-	fmt.Printf("%s\n", yamlData)
-	return nil
+	return printer.PrintItem(terraOutput.Apps, *data)
 }
 
 // *******************
-func appInputs(options OptionsDef, nsID string, appID string) (map[string]interface{}, error) {
-	client := http.Client{}
-
-	nsReq, runReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns/%s/app/%s/inputs", options.URL, nsID, appID), nil)
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-
-	if runReqErr != nil {
-		return nil, runReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, nsRespErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get application inputs: %s", data["message"].(string))
-	}
-
+func (c *Client) appInputs(ctx context.Context, nsID string, appID string) (map[string]interface{}, error) {
 	var res map[string]map[string]interface{}
-	json.NewDecoder(nsResp.Body).Decode(&res)
+	err := c.call(ctx, "GET", fmt.Sprintf("/deploy/ns/%s/app/%s/inputs", nsID, appID), nil, http.StatusOK, &res, "Failed to get application inputs")
+	if err != nil {
+		return nil, err
+	}
 	inputs := res["app"]
 
-	//respData, _ := ioutil.ReadAll(nsResp.Body)
-	//fmt.Printf("app inputs: %s", respData)
 	return inputs, nil
 
 }
 
-func endpointDefaultInputs(options OptionsDef, nsID string, endpointID string) (map[string][]string, error) {
-	client := http.Client{}
-	nsReq, runReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns/%s/endpoint/%s/defaults", options.URL, nsID, endpointID), nil)
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-
-	if runReqErr != nil {
-		return nil, runReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, nsRespErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get endpoint defaults: %s", data["message"].(string))
-	}
-	var endpointDefaults map[string]map[string][]string
-	json.NewDecoder(nsResp.Body).Decode(&endpointDefaults)
-	return endpointDefaults["defaults"], nil
-}
-
 func promptUser(label string) string {
 	fmt.Printf("%s: ", label)
 	reader := bufio.NewReader(os.Stdin)
@@ -833,79 +629,38 @@ type RunInputs struct {
 	Params map[string]string `yaml:"params"`
 }
 
-func hasSecret(options OptionsDef, nsID string, endpointID string) bool {
-	client := http.Client{}
-	nsReq, runReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns/%s/endpoint/%s/secret", options.URL, nsID, endpointID), nil)
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-
-	if runReqErr != nil {
-
-		return false
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return false
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		return false
-	}
-	return true
-}
-
-func runRun(options OptionsDef, run terraModel.Run) (string, error) {
-	client := http.Client{}
-	data, _ := json.Marshal(run)
-	fmt.Printf("Run %+v\n", run)
-	fmt.Printf("%s/deploy/ns/%s/run/%s\n", options.URL, run.Endpoint, run.AppID)
-	nsReq, runReqErr := http.NewRequest("POST", fmt.Sprintf("%s/deploy/ns/%s/run/%s", options.URL, run.Namespace, run.AppID), bytes.NewBuffer(data))
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-
-	if runReqErr != nil {
-		return "", runReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return "", nsRespErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 201 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		fmt.Printf("### %d, %+v", nsResp.StatusCode, data)
-		return "", fmt.Errorf("Failed to run application: %s", data["message"].(string))
-	}
+func (c *Client) runRun(ctx context.Context, run terraModel.Run) (string, error) {
 	var runRespData map[string]string
-	json.NewDecoder(nsResp.Body).Decode(&runRespData)
+	err := c.call(ctx, "POST", fmt.Sprintf("/deploy/ns/%s/run/%s", run.Namespace, run.AppID), run, http.StatusCreated, &runRespData, "Failed to run application")
+	if err != nil {
+		return "", err
+	}
 
 	return runRespData["run"], nil
 }
 
 // StartRun exec a run
-func StartRun(options OptionsDef, name string, nsID string, endpointID string, appID string, params string, template bool) (string, error) {
+func (c *Client) StartRun(ctx context.Context, name string, nsID string, endpointID string, appID string, params string, template bool) (string, error) {
 	paramData := make(map[string]string)
 
-	hasSecret := hasSecret(options, nsID, endpointID)
+	hasSecret, secretErr := c.HasEndpointSecret(ctx, nsID, endpointID)
+	if secretErr != nil {
+		return "", secretErr
+	}
 	if !hasSecret {
-		return "", fmt.Errorf("no known secret for this endpoint, please create one first")
+		return "", fmt.Errorf("no known secret for this endpoint, please create one first with goterra endpoint secret set")
 	}
 
 	if params == "" {
 
-		endpointInfo, _ := GetEndpoint(options, nsID, endpointID)
+		endpointInfo, _ := c.GetEndpoint(ctx, nsID, endpointID)
 
-		inputs, inputsErr := appInputs(options, nsID, appID)
+		inputs, inputsErr := c.appInputs(ctx, nsID, appID)
 		if inputsErr != nil {
 			return "", inputsErr
 		}
 
-		endpointDefaultInputParams, endpointDefaultInputError := endpointDefaultInputs(options, nsID, endpointID)
+		endpointDefaultInputParams, endpointDefaultInputError := c.GetEndpointDefaults(ctx, nsID, endpointID)
 
 		var defaultInputs map[string]interface{}
 		defaultInputs = inputs["defaults"].(map[string]interface{})
@@ -1023,162 +778,93 @@ func StartRun(options OptionsDef, name string, nsID string, endpointID string, a
 
 	sensitive := make(map[string]string)
 	runInputData := terraModel.Run{Name: name, Namespace: nsID, Inputs: paramData, Endpoint: endpointID, AppID: appID, SensitiveInputs: sensitive}
-	runID, runError := runRun(options, runInputData)
+	runID, runError := c.runRun(ctx, runInputData)
 
 	return runID, runError
 }
 
 // GetRuns returns user runs
-func GetRuns(options OptionsDef, id string) ([]terraModel.Run, error) {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/run", options.URL), nil)
+func (c *Client) GetRuns(ctx context.Context, id string) ([]terraModel.Run, error) {
+	path := "/deploy/run"
 	if id != "" {
-		nsReq, authReqErr = http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns/%s/run", options.URL, id), nil)
-	}
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-
-	if authReqErr != nil {
-		return nil, authReqErr
-
+		path = fmt.Sprintf("/deploy/ns/%s/run", id)
 	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, nsRespErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get applications: %s", data["message"].(string))
-	}
-
 	var nsResult map[string][]terraModel.Run
-	json.NewDecoder(nsResp.Body).Decode(&nsResult)
-	data := nsResult["runs"]
-	return data, nil
+	err := c.call(ctx, "GET", path, nil, http.StatusOK, &nsResult, "Failed to get runs")
+	if err != nil {
+		return nil, err
+	}
+	return nsResult["runs"], nil
 }
 
 // GetRun returns selected run
-func GetRun(options OptionsDef, nsID, id string) (*terraModel.Run, error) {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/deploy/ns/%s/run/%s", options.URL, nsID, id), nil)
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-	if authReqErr != nil {
-		return nil, authReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, authReqErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get application: %s", data["message"].(string))
-	}
-
+func (c *Client) GetRun(ctx context.Context, nsID, id string) (*terraModel.Run, error) {
 	var nsData terraModel.Run
-	json.NewDecoder(nsResp.Body).Decode(&nsData)
+	err := c.call(ctx, "GET", fmt.Sprintf("/deploy/ns/%s/run/%s", nsID, id), nil, http.StatusOK, &nsData, "Failed to get run")
+	if err != nil {
+		return nil, err
+	}
 	return &nsData, nil
 }
 
 // DeleteRun ask for run termination
-func DeleteRun(options OptionsDef, nsID string, id string) error {
-	client := http.Client{}
-
-	nsReq, authReqErr := http.NewRequest("DELETE", fmt.Sprintf("%s/deploy/ns/%s/run/%s", options.URL, nsID, id), nil)
-
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-
-	if authReqErr != nil {
-		return authReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return authReqErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return fmt.Errorf("Failed to get applications: %s", data["message"].(string))
-	}
-
-	return nil
+func (c *Client) DeleteRun(ctx context.Context, nsID string, id string) error {
+	return c.call(ctx, "DELETE", fmt.Sprintf("/deploy/ns/%s/run/%s", nsID, id), nil, http.StatusOK, nil, "Failed to delete run")
 }
 
 // ListRuns list the user runs
-func ListRuns(options OptionsDef, nsID string) error {
-	data, err := GetRuns(options, nsID)
+func (c *Client) ListRuns(ctx context.Context, printer *terraOutput.Printer, nsID string) error {
+	data, err := c.GetRuns(ctx, nsID)
 	if err != nil {
 		return err
 	}
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, '\t', tabwriter.AlignRight|tabwriter.Debug)
-	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", "ID", "Name", "Status", "Start", "End", "Namespace")
-	for _, ep := range data {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", ep.ID.Hex(), ep.Name, ep.Status, time.Unix(ep.Start, 0), time.Unix(ep.End, 0), ep.Namespace)
-	}
-	w.Flush()
-	return nil
+	return printer.PrintList(terraOutput.Runs, data)
 }
 
-// GetRunStore returns run deployment data in store
-func GetRunStore(options OptionsDef, id string) (*map[string]interface{}, error) {
-	client := http.Client{}
-	nsReq, authReqErr := http.NewRequest("GET", fmt.Sprintf("%s/store/%s", options.URL, id), nil)
-	nsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.Token))
-	nsReq.Header.Add("Content-Type", "application/json")
-	if authReqErr != nil {
-		return nil, authReqErr
-
-	}
-	nsResp, nsRespErr := client.Do(nsReq)
-	if nsRespErr != nil {
-		return nil, authReqErr
-
-	}
-	defer nsResp.Body.Close()
-	if nsResp.StatusCode != 200 {
-		var data map[string]interface{}
-		json.NewDecoder(nsResp.Body).Decode(&data)
-		return nil, fmt.Errorf("Failed to get application: %s", data["message"].(string))
-	}
+// RunWithStore is a run with its deployment data in store
+type RunWithStore struct {
+	Run   terraModel.Run          `json:"run"`
+	Store *map[string]interface{} `json:"store"`
+}
 
+// GetRunStore returns run deployment data in store
+func (c *Client) GetRunStore(ctx context.Context, id string) (*map[string]interface{}, error) {
 	var nsData map[string]interface{}
-	json.NewDecoder(nsResp.Body).Decode(&nsData)
+	err := c.call(ctx, "GET", fmt.Sprintf("/store/%s", id), nil, http.StatusOK, &nsData, "Failed to get run store data")
+	if err != nil {
+		return nil, err
+	}
 	return &nsData, nil
 }
 
 // ShowRun displays the run info
-func ShowRun(options OptionsDef, nsID string, id string, store bool) error {
-
-	data, err := GetRun(options, nsID, id)
-
+func (c *Client) ShowRun(ctx context.Context, printer *terraOutput.Printer, nsID string, id string, store bool) error {
+	data, err := c.GetRun(ctx, nsID, id)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("id: %s\n", data.ID.Hex())
-	yamlData, _ := yaml.Marshal(data)
-	fmt.Printf("%s\n", yamlData)
+	if !store {
+		return printer.PrintItem(terraOutput.Runs, *data)
+	}
 
-	fmt.Println("Store data")
-	if data.Deployment == "" {
-		fmt.Println("\tno data")
-	} else {
-		storeData, errData := GetRunStore(options, data.Deployment)
-		if errData != nil {
-			return errData
+	var storeData *map[string]interface{}
+	if data.Deployment != "" {
+		storeData, err = c.GetRunStore(ctx, data.Deployment)
+		if err != nil {
+			return err
 		}
-		yamlData, _ = yaml.Marshal(storeData)
-		fmt.Printf("%s\n", yamlData)
 	}
-	return nil
+	switch printer.Format {
+	case terraOutput.FormatJSON, terraOutput.FormatYAML, terraOutput.FormatTemplate, terraOutput.FormatJSONPath:
+		return printer.PrintData(RunWithStore{Run: *data, Store: storeData})
+	}
+	if err := printer.PrintItem(terraOutput.Runs, *data); err != nil {
+		return err
+	}
+	fmt.Fprintln(printer.Out, "Store data")
+	if storeData == nil {
+		fmt.Fprintln(printer.Out, "\tno data")
+		return nil
+	}
+	return printer.PrintData(storeData)
 }
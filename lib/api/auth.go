@@ -0,0 +1,125 @@
+package goterraapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// tokenRefreshMargin is how long before expiration a token is renewed
+const tokenRefreshMargin = 30 * time.Second
+
+// CurrentToken returns the authentication token in use
+func (c *Client) CurrentToken() string {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return c.Token
+}
+
+// SetToken sets the authentication token, safe to use while client is shared
+func (c *Client) SetToken(token string) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	c.Token = token
+}
+
+// TokenExpiry returns the expiration date of a JWT token from its exp claim
+//
+// Signature is not checked, ok is false if token has no readable exp claim
+func TokenExpiry(token string) (expiry time.Time, ok bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return expiry, false
+	}
+	payload, payloadErr := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if payloadErr != nil {
+		return expiry, false
+	}
+	var claims struct {
+		Exp json.Number `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == "" {
+		return expiry, false
+	}
+	exp, expErr := claims.Exp.Float64()
+	if expErr != nil {
+		return expiry, false
+	}
+	return time.Unix(int64(exp), 0), true
+}
+
+// tokenExpired checks if token will expire within tokenRefreshMargin
+func tokenExpired(token string) bool {
+	expiry, ok := TokenExpiry(token)
+	return ok && time.Now().Add(tokenRefreshMargin).After(expiry)
+}
+
+// canRefresh checks if client can get a new token
+func (c *Client) canRefresh() bool {
+	return c.APIKey != ""
+}
+
+// refreshToken gets a new token with client api key if staleToken is still the one in use
+//
+// Concurrent callers with the same stale token wait for a single login
+func (c *Client) refreshToken(ctx context.Context, staleToken string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if c.CurrentToken() != staleToken {
+		// Already refreshed by another request
+		return nil
+	}
+	token, loginErr := c.Login(ctx)
+	if loginErr != nil {
+		return loginErr
+	}
+	c.SetToken(token)
+	return nil
+}
+
+// ensureToken renews the token before sending a request if it is about to expire
+func (c *Client) ensureToken(ctx context.Context) error {
+	token := c.CurrentToken()
+	if token == "" || !c.canRefresh() || !tokenExpired(token) {
+		return nil
+	}
+	return c.refreshToken(ctx, token)
+}
+
+// replayWithNewToken renews the token rejected by a 401 answer and sends request again
+func (c *Client) replayWithNewToken(req *http.Request) (*http.Response, error) {
+	staleToken := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if refreshErr := c.refreshToken(req.Context(), staleToken); refreshErr != nil {
+		return nil, refreshErr
+	}
+	if req.GetBody != nil {
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return nil, bodyErr
+		}
+		req.Body = body
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.CurrentToken()))
+	return c.send(req)
+}
+
+// Ping checks if Goterra server answers an authenticated request with current token
+//
+// Returned status code tells if token was accepted, error is only set if server is unreachable
+func (c *Client) Ping(ctx context.Context) (int, time.Duration, error) {
+	req, reqErr := c.newRequest(ctx, "GET", "/deploy/ns", nil)
+	if reqErr != nil {
+		return 0, 0, reqErr
+	}
+	start := time.Now()
+	resp, respErr := c.send(req)
+	if respErr != nil {
+		return 0, 0, respErr
+	}
+	resp.Body.Close()
+	return resp.StatusCode, time.Since(start), nil
+}
@@ -0,0 +1,157 @@
+package goterraapi
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+)
+
+// testJWT returns an unsigned JWT token expiring at exp
+func testJWT(exp time.Time) string {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp": %d}`, exp.Unix())))
+	return "header." + claims + ".signature"
+}
+
+// authServer accepts requests with its current token, logins with api key get a new token
+type authServer struct {
+	mu     sync.Mutex
+	token  string
+	logins int
+	// rejectAll answers 401 to all requests but logins
+	rejectAll bool
+	requests  int
+	bodies    []string
+}
+
+func (s *authServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path == "/auth/api" {
+		if r.Header.Get("X-API-Key") != "apikey" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.logins++
+		s.token = testJWT(time.Now().Add(time.Hour))
+		fmt.Fprintf(w, `{"token": "%s"}`, s.token)
+		return
+	}
+	s.requests++
+	body, _ := ioutil.ReadAll(r.Body)
+	s.bodies = append(s.bodies, string(body))
+	if s.rejectAll || r.Header.Get("Authorization") != "Bearer "+s.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"message": "invalid token"}`)
+		return
+	}
+	if r.Method == "POST" {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"endpoint": "5e8c1d2a9b1e8a0001000001"}`)
+		return
+	}
+	fmt.Fprint(w, `{"ns": []}`)
+}
+
+// newAuthClient returns a client with api key and token of auth server s
+func newAuthClient(s *authServer, token string) (*Client, func()) {
+	c, server := newTestClient(s.handle)
+	c.APIKey = "apikey"
+	c.SetToken(token)
+	return c, server.Close
+}
+
+func TestRefreshBeforeExpiry(t *testing.T) {
+	s := &authServer{}
+	c, closeServer := newAuthClient(s, testJWT(time.Now().Add(10*time.Second)))
+	defer closeServer()
+	s.token = c.CurrentToken()
+	if _, err := c.GetNamespaces(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	if s.logins != 1 || s.requests != 1 {
+		t.Errorf("expected a login before a single request, got %d logins and %d requests", s.logins, s.requests)
+	}
+	if c.CurrentToken() != s.token {
+		t.Error("expected client to use new token")
+	}
+
+	// Token not about to expire is kept
+	if _, err := c.GetNamespaces(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	if s.logins != 1 {
+		t.Errorf("expected no other login, got %d logins", s.logins)
+	}
+}
+
+func TestRefreshConcurrent(t *testing.T) {
+	s := &authServer{token: "valid"}
+	c, closeServer := newAuthClient(s, "stale")
+	defer closeServer()
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.GetNamespaces(context.Background(), false)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unexpected error %s", err)
+		}
+	}
+	if s.logins != 1 {
+		t.Errorf("expected a single login, got %d", s.logins)
+	}
+}
+
+func TestRefreshReplayOnce(t *testing.T) {
+	s := &authServer{rejectAll: true}
+	c, closeServer := newAuthClient(s, "stale")
+	defer closeServer()
+	_, err := c.GetNamespaces(context.Background(), false)
+	if !IsUnauthorized(err) {
+		t.Errorf("expected an unauthorized error, got %v", err)
+	}
+	if s.logins != 1 || s.requests != 2 {
+		t.Errorf("expected a single replay, got %d logins and %d requests", s.logins, s.requests)
+	}
+
+	// Without api key, token can't be renewed
+	s.logins, s.requests = 0, 0
+	c.APIKey = ""
+	c.GetNamespaces(context.Background(), false)
+	if s.logins != 0 || s.requests != 1 {
+		t.Errorf("expected no replay without api key, got %d logins and %d requests", s.logins, s.requests)
+	}
+}
+
+func TestRefreshReplayBody(t *testing.T) {
+	s := &authServer{token: "valid"}
+	c, closeServer := newAuthClient(s, "stale")
+	defer closeServer()
+	endpoint := &terraModel.EndPoint{Name: "genouest", Kind: "openstack"}
+	id, err := c.CreateEndpoint(context.Background(), "ns", endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "5e8c1d2a9b1e8a0001000001" {
+		t.Errorf("unexpected id %s", id)
+	}
+	if len(s.bodies) != 2 || s.bodies[1] != s.bodies[0] || !strings.Contains(s.bodies[1], `"genouest"`) {
+		t.Errorf("expected replayed body, got %q", s.bodies)
+	}
+}
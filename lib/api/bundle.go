@@ -0,0 +1,373 @@
+package goterraapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	terraBundle "github.com/osallou/goterra-cli/lib/bundle"
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+)
+
+// ExportNamespace copies namespace endpoints, recipes, templates and applications in a bundle
+//
+// Public objects of other namespaces are not exported, endpoint secrets can't be exported
+func (c *Client) ExportNamespace(ctx context.Context, nsID string) (*terraBundle.Bundle, error) {
+	ns, err := c.GetNamespace(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	bundle := terraBundle.New(c.BaseURL, *ns)
+
+	endpoints, err := c.GetEndpoints(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.Namespace == nsID {
+			bundle.Endpoints = append(bundle.Endpoints, endpoint)
+		}
+	}
+	recipes, err := c.GetRecipes(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	for _, recipe := range recipes {
+		if recipe.Namespace == nsID {
+			bundle.Recipes = append(bundle.Recipes, recipe)
+		}
+	}
+	templates, err := c.GetTemplates(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	for _, template := range templates {
+		if template.Namespace == nsID {
+			bundle.Templates = append(bundle.Templates, template)
+		}
+	}
+	apps, err := c.GetApps(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		if app.Namespace == nsID {
+			bundle.Apps = append(bundle.Apps, app)
+		}
+	}
+	return bundle, nil
+}
+
+// ImportAction is an object creation, or skip, done by an import
+type ImportAction struct {
+	Kind string
+	Name string
+	// SourceID is the object id in bundle
+	SourceID string
+	// ID is the object id in target namespace, empty on dry run for new objects
+	ID      string
+	Skipped bool
+	// Warnings lists references to objects not in bundle, kept as is
+	Warnings []string
+}
+
+// String describes action
+func (a ImportAction) String() string {
+	action := "create"
+	if a.Skipped {
+		action = "skip"
+	}
+	desc := fmt.Sprintf("%s %s %s (%s)", action, a.Kind, a.Name, a.SourceID)
+	if a.Skipped {
+		desc += fmt.Sprintf(": already exists as %s", a.ID)
+	} else if a.ID != "" {
+		desc += fmt.Sprintf(" => %s", a.ID)
+	}
+	if len(a.Warnings) > 0 {
+		desc += fmt.Sprintf(" [warning: %s]", strings.Join(a.Warnings, ", "))
+	}
+	return desc
+}
+
+// bundleImport holds an import state
+type bundleImport struct {
+	client *Client
+	nsID   string
+	dryRun bool
+	report func(ImportAction)
+	// ids maps bundle object ids to target object ids
+	ids map[string]string
+	// bundleIDs contains ids of all bundle objects
+	bundleIDs map[string]bool
+}
+
+// ImportNamespace creates bundle objects in namespace nsID, rewriting references to bundle objects with new ids
+//
+// Endpoints, templates, recipes (parents first) then applications are created.
+// All versions of an object are created, oldest first, and linked to their previous version.
+// Objects with the same name already in namespace are skipped, references to any of their versions use
+// the newest existing object. References to objects not in bundle are kept as is.
+// If nsID is empty, namespace is expected to be new (dry run only).
+// If dryRun is set, nothing is created. report is called for each object.
+func (c *Client) ImportNamespace(ctx context.Context, bundle *terraBundle.Bundle, nsID string, dryRun bool, report func(ImportAction)) error {
+	if nsID == "" && !dryRun {
+		return fmt.Errorf("missing target namespace")
+	}
+	imp := &bundleImport{
+		client:    c,
+		nsID:      nsID,
+		dryRun:    dryRun,
+		report:    report,
+		ids:       make(map[string]string),
+		bundleIDs: make(map[string]bool),
+	}
+	for _, endpoint := range bundle.Endpoints {
+		imp.bundleIDs[endpoint.ID.Hex()] = true
+	}
+	for _, recipe := range bundle.Recipes {
+		imp.bundleIDs[recipe.ID.Hex()] = true
+	}
+	for _, template := range bundle.Templates {
+		imp.bundleIDs[template.ID.Hex()] = true
+	}
+	for _, app := range bundle.Apps {
+		imp.bundleIDs[app.ID.Hex()] = true
+	}
+
+	if err := imp.importEndpoints(ctx, bundle.Endpoints); err != nil {
+		return err
+	}
+	if err := imp.importTemplates(ctx, bundle.Templates); err != nil {
+		return err
+	}
+	if err := imp.importRecipes(ctx, bundle.Recipes); err != nil {
+		return err
+	}
+	return imp.importApps(ctx, bundle.Apps)
+}
+
+// importObject creates an object, or skips it if its name is in existing objects, then records its id and reports action
+func (imp *bundleImport) importObject(action ImportAction, existing map[string]string, create func() (string, error)) error {
+	if id, ok := existing[action.Name]; ok {
+		action.ID = id
+		action.Skipped = true
+	} else if !imp.dryRun {
+		id, err := create()
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", action.Kind, action.Name, err)
+		}
+		action.ID = id
+	}
+	imp.ids[action.SourceID] = action.ID
+	if action.ID == "" {
+		// Dry run, object will be created
+		imp.ids[action.SourceID] = "new:" + action.SourceID
+	}
+	imp.report(action)
+	return nil
+}
+
+// previousVersion returns the target id of an object previous version, ok is false if it is in bundle but not imported yet
+//
+// Previous versions not in bundle are dropped
+func (imp *bundleImport) previousVersion(id string) (string, bool) {
+	if newID, ok := imp.ids[id]; ok {
+		return newID, true
+	}
+	return "", !imp.bundleIDs[id]
+}
+
+// addExisting records an object already in namespace, of several versions the newest one is kept
+func addExisting(existing map[string]string, timestamps map[string]int64, name string, id string, timestamp int64) {
+	if _, ok := existing[name]; ok && timestamp < timestamps[name] {
+		return
+	}
+	existing[name] = id
+	timestamps[name] = timestamp
+}
+
+// rewrite returns the target id of a referenced object, warnings are updated if reference is not in bundle
+func (imp *bundleImport) rewrite(kind string, id string, warnings *[]string) (string, bool) {
+	if id == "" {
+		return id, true
+	}
+	if newID, ok := imp.ids[id]; ok {
+		return newID, true
+	}
+	if !imp.bundleIDs[id] {
+		*warnings = append(*warnings, fmt.Sprintf("%s %s not in bundle", kind, id))
+		return id, true
+	}
+	// In bundle but not imported yet
+	return id, false
+}
+
+func (imp *bundleImport) importEndpoints(ctx context.Context, endpoints []terraModel.EndPoint) error {
+	existing := make(map[string]string)
+	if imp.nsID != "" {
+		current, err := imp.client.GetEndpoints(ctx, imp.nsID)
+		if err != nil {
+			return err
+		}
+		for _, endpoint := range current {
+			if endpoint.Namespace == imp.nsID {
+				existing[endpoint.Name] = endpoint.ID.Hex()
+			}
+		}
+	}
+	for _, endpoint := range endpoints {
+		newEndpoint := endpoint
+		newEndpoint.ID = primitive.NilObjectID
+		newEndpoint.Namespace = imp.nsID
+		action := ImportAction{Kind: "endpoint", Name: endpoint.Name, SourceID: endpoint.ID.Hex()}
+		err := imp.importObject(action, existing, func() (string, error) {
+			return imp.client.CreateEndpoint(ctx, imp.nsID, &newEndpoint)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *bundleImport) importTemplates(ctx context.Context, templates []terraModel.Template) error {
+	existing := make(map[string]string)
+	if imp.nsID != "" {
+		current, err := imp.client.GetTemplates(ctx, imp.nsID)
+		if err != nil {
+			return err
+		}
+		timestamps := make(map[string]int64)
+		for _, template := range current {
+			if template.Namespace == imp.nsID {
+				addExisting(existing, timestamps, template.Name, template.ID.Hex(), template.Timestamp)
+			}
+		}
+	}
+	// Previous versions must be created first to get their new id
+	sorted := make([]terraModel.Template, len(templates))
+	copy(sorted, templates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp < sorted[j].Timestamp
+	})
+	for _, template := range sorted {
+		newTemplate := template
+		newTemplate.ID = primitive.NilObjectID
+		newTemplate.Namespace = imp.nsID
+		newTemplate.Previous, _ = imp.previousVersion(template.Previous)
+		action := ImportAction{Kind: "template", Name: template.Name, SourceID: template.ID.Hex()}
+		err := imp.importObject(action, existing, func() (string, error) {
+			return imp.client.CreateTemplate(ctx, imp.nsID, &newTemplate)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *bundleImport) importRecipes(ctx context.Context, recipes []terraModel.Recipe) error {
+	existing := make(map[string]string)
+	if imp.nsID != "" {
+		current, err := imp.client.GetRecipes(ctx, imp.nsID)
+		if err != nil {
+			return err
+		}
+		timestamps := make(map[string]int64)
+		for _, recipe := range current {
+			if recipe.Namespace == imp.nsID {
+				addExisting(existing, timestamps, recipe.Name, recipe.ID.Hex(), recipe.Timestamp)
+			}
+		}
+	}
+	// Parents and previous versions must be created first to get their new id
+	pending := recipes
+	for len(pending) > 0 {
+		var waiting []terraModel.Recipe
+		for _, recipe := range pending {
+			var warnings []string
+			parent, parentOK := imp.rewrite("parent recipe", recipe.ParentRecipe, &warnings)
+			previous, previousOK := imp.previousVersion(recipe.Previous)
+			if !parentOK || !previousOK {
+				waiting = append(waiting, recipe)
+				continue
+			}
+			newRecipe := recipe
+			newRecipe.ID = primitive.NilObjectID
+			newRecipe.Namespace = imp.nsID
+			newRecipe.Previous = previous
+			newRecipe.ParentRecipe = parent
+			action := ImportAction{Kind: "recipe", Name: recipe.Name, SourceID: recipe.ID.Hex(), Warnings: warnings}
+			err := imp.importObject(action, existing, func() (string, error) {
+				return imp.client.CreateRecipe(ctx, imp.nsID, &newRecipe)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(waiting) == len(pending) {
+			names := make([]string, len(waiting))
+			for i, recipe := range waiting {
+				names[i] = recipe.Name
+			}
+			return fmt.Errorf("recipes %s have circular parent or version references", strings.Join(names, ", "))
+		}
+		pending = waiting
+	}
+	return nil
+}
+
+func (imp *bundleImport) importApps(ctx context.Context, apps []terraModel.Application) error {
+	existing := make(map[string]string)
+	if imp.nsID != "" {
+		current, err := imp.client.GetApps(ctx, imp.nsID)
+		if err != nil {
+			return err
+		}
+		timestamps := make(map[string]int64)
+		for _, app := range current {
+			if app.Namespace == imp.nsID {
+				addExisting(existing, timestamps, app.Name, app.ID.Hex(), app.Timestamp)
+			}
+		}
+	}
+	// Previous versions must be created first to get their new id
+	sorted := make([]terraModel.Application, len(apps))
+	copy(sorted, apps)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp < sorted[j].Timestamp
+	})
+	for _, app := range sorted {
+		var warnings []string
+		newApp := app
+		newApp.ID = primitive.NilObjectID
+		newApp.Namespace = imp.nsID
+		newApp.Previous, _ = imp.previousVersion(app.Previous)
+		newApp.Template, _ = imp.rewrite("template", app.Template, &warnings)
+		newApp.Recipes = make([]string, len(app.Recipes))
+		for i, recipe := range app.Recipes {
+			newApp.Recipes[i], _ = imp.rewrite("recipe", recipe, &warnings)
+		}
+		if app.TemplateRecipes != nil {
+			newApp.TemplateRecipes = make(map[string][]string, len(app.TemplateRecipes))
+			for key, recipes := range app.TemplateRecipes {
+				newRecipes := make([]string, len(recipes))
+				for i, recipe := range recipes {
+					newRecipes[i], _ = imp.rewrite("recipe", recipe, &warnings)
+				}
+				newApp.TemplateRecipes[key] = newRecipes
+			}
+		}
+		action := ImportAction{Kind: "app", Name: app.Name, SourceID: app.ID.Hex(), Warnings: warnings}
+		err := imp.importObject(action, existing, func() (string, error) {
+			return imp.client.CreateApp(ctx, imp.nsID, &newApp)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
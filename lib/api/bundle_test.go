@@ -0,0 +1,148 @@
+package goterraapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	terraBundle "github.com/osallou/goterra-cli/lib/bundle"
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+)
+
+// importServer is a namespace with existing recipes, recording created recipes and applications
+type importServer struct {
+	mu       sync.Mutex
+	existing []terraModel.Recipe
+	recipes  []terraModel.Recipe
+	apps     []terraModel.Application
+}
+
+func (s *importServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kind := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if r.Method == "GET" {
+		if kind == "recipe" {
+			json.NewEncoder(w).Encode(map[string][]terraModel.Recipe{"recipes": s.existing})
+			return
+		}
+		w.Write([]byte(`{}`))
+		return
+	}
+	id := primitive.NewObjectID()
+	switch kind {
+	case "recipe":
+		var recipe terraModel.Recipe
+		json.NewDecoder(r.Body).Decode(&recipe)
+		recipe.ID = id
+		s.recipes = append(s.recipes, recipe)
+	case "app":
+		var app terraModel.Application
+		json.NewDecoder(r.Body).Decode(&app)
+		app.ID = id
+		s.apps = append(s.apps, app)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, `{"%s": "%s"}`, kind, id.Hex())
+}
+
+// versionedBundle returns a bundle with two versions of a recipe, newest first, and an app using the newest one
+func versionedBundle() *terraBundle.Bundle {
+	old := terraModel.Recipe{ID: primitive.NewObjectID(), Name: "slurm", Namespace: "source", Timestamp: 1}
+	current := terraModel.Recipe{ID: primitive.NewObjectID(), Name: "slurm", Namespace: "source", Timestamp: 2, Previous: old.ID.Hex()}
+	app := terraModel.Application{ID: primitive.NewObjectID(), Name: "cluster", Namespace: "source", Recipes: []string{current.ID.Hex()}}
+	return &terraBundle.Bundle{
+		Recipes: []terraModel.Recipe{current, old},
+		Apps:    []terraModel.Application{app},
+	}
+}
+
+func TestImportVersions(t *testing.T) {
+	s := &importServer{}
+	c, server := newTestClient(s.handle)
+	defer server.Close()
+	var actions []ImportAction
+	err := c.ImportNamespace(context.Background(), versionedBundle(), "target", false, func(action ImportAction) {
+		actions = append(actions, action)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.recipes) != 2 || len(s.apps) != 1 {
+		t.Fatalf("expected 2 recipes and an app, got %d recipes and %d apps", len(s.recipes), len(s.apps))
+	}
+	old, current := s.recipes[0], s.recipes[1]
+	if old.Timestamp != 1 || old.Previous != "" || current.Previous != old.ID.Hex() {
+		t.Errorf("expected versions created oldest first and linked, got %+v", s.recipes)
+	}
+	if recipes := s.apps[0].Recipes; len(recipes) != 1 || recipes[0] != current.ID.Hex() {
+		t.Errorf("expected app to use new recipe version %s, got %v", current.ID.Hex(), recipes)
+	}
+	for _, action := range actions {
+		if action.Skipped {
+			t.Errorf("unexpected skip: %s", action)
+		}
+	}
+}
+
+func TestImportVersionsDryRun(t *testing.T) {
+	s := &importServer{}
+	c, server := newTestClient(s.handle)
+	defer server.Close()
+	bundle := versionedBundle()
+	var actions []ImportAction
+	err := c.ImportNamespace(context.Background(), bundle, "target", true, func(action ImportAction) {
+		actions = append(actions, action)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.recipes) != 0 || len(s.apps) != 0 {
+		t.Errorf("expected nothing created on dry run")
+	}
+	if len(actions) != 3 {
+		t.Fatalf("expected 3 actions, got %v", actions)
+	}
+	for _, action := range actions {
+		if action.Skipped {
+			t.Errorf("unexpected skip: %s", action)
+		}
+	}
+}
+
+func TestImportExistingVersions(t *testing.T) {
+	oldID, currentID := primitive.NewObjectID(), primitive.NewObjectID()
+	s := &importServer{existing: []terraModel.Recipe{
+		{ID: currentID, Name: "slurm", Namespace: "target", Timestamp: 5, Previous: oldID.Hex()},
+		{ID: oldID, Name: "slurm", Namespace: "target", Timestamp: 3},
+	}}
+	c, server := newTestClient(s.handle)
+	defer server.Close()
+	var actions []ImportAction
+	err := c.ImportNamespace(context.Background(), versionedBundle(), "target", false, func(action ImportAction) {
+		actions = append(actions, action)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.recipes) != 0 {
+		t.Errorf("expected existing recipe to be skipped, got %+v", s.recipes)
+	}
+	for _, action := range actions {
+		if action.Kind == "recipe" && (!action.Skipped || action.ID != currentID.Hex()) {
+			t.Errorf("expected skip to newest existing version, got %s", action)
+		}
+	}
+	if len(s.apps) != 1 || len(s.apps[0].Recipes) != 1 || s.apps[0].Recipes[0] != currentID.Hex() {
+		t.Errorf("expected app to use newest existing recipe %s, got %+v", currentID.Hex(), s.apps)
+	}
+}
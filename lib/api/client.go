@@ -0,0 +1,113 @@
+package goterraapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// DefaultUserAgent is the user agent sent when none is set on the client
+const DefaultUserAgent = "goterra-cli"
+
+// Client is a Goterra API client, safe to share between calls
+type Client struct {
+	// HTTPClient is used to send requests, can be replaced for custom transports
+	HTTPClient *http.Client
+	// BaseURL is the URL to Goterra host
+	BaseURL string
+	// UserAgent is sent with every request
+	UserAgent string
+	// APIKey is the user api key used to get a token
+	APIKey string
+	// Token is the authentication token sent as bearer, renewed with APIKey
+	// when expired. Use SetToken and CurrentToken once client is shared
+	Token string
+	// Retry is the retry policy for idempotent requests
+	Retry RetryPolicy
+
+	tokenMu   sync.RWMutex
+	refreshMu sync.Mutex
+}
+
+// NewClient creates a client from connection options
+func NewClient(options OptionsDef) *Client {
+	return &Client{
+		HTTPClient: &http.Client{},
+		BaseURL:    options.URL,
+		UserAgent:  DefaultUserAgent,
+		APIKey:     options.APIKEY,
+		Token:      options.Token,
+		Retry:      DefaultRetryPolicy(),
+	}
+}
+
+// newRequest creates an authenticated request to Goterra, body is json encoded if not nil
+func (c *Client) newRequest(ctx context.Context, method string, path string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, dataErr := json.Marshal(body)
+		if dataErr != nil {
+			return nil, dataErr
+		}
+		reader = bytes.NewBuffer(data)
+	}
+	req, reqErr := http.NewRequest(method, fmt.Sprintf("%s%s", c.BaseURL, path), reader)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	req = req.WithContext(ctx)
+	if token := c.CurrentToken(); token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+	req.Header.Add("Content-Type", "application/json")
+	userAgent := c.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	return req, nil
+}
+
+// do sends request and decodes json answer in result if not nil
+//
+// An *APIError with errMsg as operation is returned if status code is not the expected one
+func (c *Client) do(req *http.Request, expectedStatus int, result interface{}, errMsg string) error {
+	resp, respErr := c.send(req)
+	if respErr != nil {
+		return respErr
+	}
+	if resp.StatusCode == http.StatusUnauthorized && req.Header.Get("Authorization") != "" && c.canRefresh() {
+		// Token may have expired, renew it and replay request once
+		resp.Body.Close()
+		resp, respErr = c.replayWithNewToken(req)
+		if respErr != nil {
+			return respErr
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != expectedStatus {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return newAPIError(errMsg, req, resp.StatusCode, body)
+	}
+	if result != nil {
+		json.NewDecoder(resp.Body).Decode(result)
+	}
+	return nil
+}
+
+// call creates and sends a request, see newRequest and do
+func (c *Client) call(ctx context.Context, method string, path string, body interface{}, expectedStatus int, result interface{}, errMsg string) error {
+	if tokenErr := c.ensureToken(ctx); tokenErr != nil {
+		return tokenErr
+	}
+	req, reqErr := c.newRequest(ctx, method, path, body)
+	if reqErr != nil {
+		return reqErr
+	}
+	return c.do(req, expectedStatus, result, errMsg)
+}
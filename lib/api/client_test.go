@@ -0,0 +1,127 @@
+package goterraapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client of a test server, without retry delays, server must be closed
+func newTestClient(handler http.HandlerFunc) (*Client, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	c := NewClient(OptionsDef{URL: server.URL, Token: "token"})
+	c.Retry.BaseDelay = time.Millisecond
+	c.Retry.MaxDelay = time.Millisecond
+	return c, server
+}
+
+// roundTripFunc is an http.RoundTripper function
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestClientTransport(t *testing.T) {
+	var got *http.Request
+	c := NewClient(OptionsDef{URL: "http://goterra.test", Token: "token"})
+	c.UserAgent = "test-agent"
+	c.HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		got = req
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       ioutil.NopCloser(strings.NewReader(`{"ns": {"name": "lab", "owners": ["alice"]}}`)),
+			Request:    req,
+		}, nil
+	})}
+	ns, err := c.GetNamespace(context.Background(), "123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ns.Name != "lab" || len(ns.Owners) != 1 || ns.Owners[0] != "alice" {
+		t.Errorf("unexpected namespace %+v", ns)
+	}
+	if got.URL.String() != "http://goterra.test/deploy/ns/123" || got.Method != "GET" {
+		t.Errorf("unexpected request %s %s", got.Method, got.URL)
+	}
+	if got.Header.Get("Authorization") != "Bearer token" || got.Header.Get("User-Agent") != "test-agent" {
+		t.Errorf("unexpected headers %v", got.Header)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		status  int
+		body    string
+		message string
+		check   func(error) bool
+	}{
+		{http.StatusNotFound, `{"message": "namespace not found"}`, "namespace not found", IsNotFound},
+		{http.StatusUnauthorized, `{"error": "invalid token"}`, "invalid token", IsUnauthorized},
+		{http.StatusForbidden, "not a member\nof namespace", "not a member", IsForbidden},
+		{http.StatusConflict, `{"message": "already exists"}`, "already exists", IsConflict},
+		{http.StatusInternalServerError, "<html><body>Internal error</body></html>", "", IsServerError},
+		{http.StatusBadRequest, "", "", func(err error) bool { return StatusCode(err) == http.StatusBadRequest }},
+	}
+	for _, test := range tests {
+		c, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+			fmt.Fprint(w, test.body)
+		})
+		_, err := c.GetNamespace(context.Background(), "123")
+		server.Close()
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Errorf("%d: expected an APIError, got %v", test.status, err)
+			continue
+		}
+		if !test.check(err) || !test.check(fmt.Errorf("wrapped: %w", err)) {
+			t.Errorf("%d: unexpected status check result for %v", test.status, err)
+		}
+		if apiErr.Message != test.message || string(apiErr.Body) != test.body {
+			t.Errorf("%d: expected message %q, got %q (body %q)", test.status, test.message, apiErr.Message, apiErr.Body)
+		}
+		if apiErr.Op != "Failed to get namespace" || apiErr.Method != "GET" || !strings.HasSuffix(apiErr.URL, "/deploy/ns/123") {
+			t.Errorf("%d: unexpected error %+v", test.status, apiErr)
+		}
+		if IsNotFound(err) != (test.status == http.StatusNotFound) {
+			t.Errorf("%d: unexpected IsNotFound", test.status)
+		}
+	}
+	if StatusCode(errors.New("other")) != 0 || IsServerError(nil) {
+		t.Error("expected no status for non API errors")
+	}
+}
+
+func TestClientCancel(t *testing.T) {
+	started := make(chan bool)
+	c, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	start := time.Now()
+	_, err := c.GetNamespaces(ctx, false)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("request was not stopped, took %s", elapsed)
+	}
+}
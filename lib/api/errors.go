@@ -0,0 +1,90 @@
+package goterraapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody is the maximum number of bytes of an error answer kept in APIError
+const maxErrorBody = 64 * 1024
+
+// APIError is returned when Goterra answers with an unexpected status code
+type APIError struct {
+	// Op is the failed operation, e.g. "Failed to get namespace"
+	Op         string
+	StatusCode int
+	Method     string
+	URL        string
+	// Message is the server message, if any
+	Message string
+	// Body is the raw (possibly truncated) answer body
+	Body []byte
+}
+
+func (e *APIError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("%s: %s [%d %s %s]", e.Op, message, e.StatusCode, e.Method, e.URL)
+}
+
+// newAPIError creates an APIError from an answer body, extracting server message if body is json
+func newAPIError(op string, req *http.Request, statusCode int, body []byte) *APIError {
+	apiErr := &APIError{
+		Op:         op,
+		StatusCode: statusCode,
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Body:       body,
+	}
+	var data map[string]interface{}
+	if json.Unmarshal(body, &data) == nil {
+		if message, ok := data["message"].(string); ok {
+			apiErr.Message = message
+		} else if message, ok := data["error"].(string); ok {
+			apiErr.Message = message
+		}
+	} else if len(body) > 0 && !strings.HasPrefix(strings.TrimSpace(string(body)), "<") {
+		// Plain text answer, html pages are only kept in Body
+		apiErr.Message = strings.SplitN(strings.TrimSpace(string(body)), "\n", 2)[0]
+	}
+	return apiErr
+}
+
+// StatusCode returns the http status code of an APIError, possibly wrapped, 0 for other errors
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound checks if error is a not found (404) API error
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsUnauthorized checks if error is an unauthorized (401) API error
+func IsUnauthorized(err error) bool {
+	return StatusCode(err) == http.StatusUnauthorized
+}
+
+// IsForbidden checks if error is a forbidden (403) API error
+func IsForbidden(err error) bool {
+	return StatusCode(err) == http.StatusForbidden
+}
+
+// IsConflict checks if error is a conflict (409) API error
+func IsConflict(err error) bool {
+	return StatusCode(err) == http.StatusConflict
+}
+
+// IsServerError checks if error is a 5xx API error
+func IsServerError(err error) bool {
+	return StatusCode(err) >= 500
+}
@@ -0,0 +1,93 @@
+package goterraapi
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	terraOutput "github.com/osallou/goterra-cli/lib/output"
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+)
+
+// runFailed checks if run status is a failure
+func runFailed(run terraModel.Run) bool {
+	return strings.Contains(run.Status, "fail")
+}
+
+// runEnded checks if run resources are released
+func runEnded(run terraModel.Run) bool {
+	return run.End != 0 || run.Status == "destroy_success"
+}
+
+// NamespaceReport computes namespace usage between since and until, zero values mean no limit
+//
+// Runs are counted if they were deployed during window, run hours are limited to window.
+// Runs not ended are counted until now.
+func (c *Client) NamespaceReport(ctx context.Context, ns terraModel.NSData, since time.Time, until time.Time) (*terraOutput.NSReport, error) {
+	nsID := ns.ID.Hex()
+	report := &terraOutput.NSReport{
+		Namespace: nsID,
+		Name:      ns.Name,
+		Owners:    len(ns.Owners),
+		Members:   len(ns.Members),
+	}
+	now := time.Now()
+	if until.IsZero() || until.After(now) {
+		until = now
+	}
+
+	runs, err := c.GetRuns(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make(map[string]bool)
+	var runTime time.Duration
+	for _, run := range runs {
+		if run.Start == 0 {
+			continue
+		}
+		start := time.Unix(run.Start, 0)
+		end := until
+		if run.End != 0 {
+			end = time.Unix(run.End, 0)
+		} else if runEnded(run) || runFailed(run) {
+			// No end date, duration is unknown
+			end = start
+		}
+		if start.After(until) || (!since.IsZero() && end.Before(since)) {
+			continue
+		}
+		report.Runs++
+		if runFailed(run) {
+			report.FailedRuns++
+		} else if !runEnded(run) {
+			report.ActiveRuns++
+		}
+		if run.Endpoint != "" {
+			endpoints[run.Endpoint] = true
+		}
+		if !since.IsZero() && start.Before(since) {
+			start = since
+		}
+		if end.After(until) {
+			end = until
+		}
+		if end.After(start) {
+			runTime += end.Sub(start)
+		}
+	}
+	report.Endpoints = len(endpoints)
+	report.RunHours = math.Round(runTime.Hours()*100) / 100
+
+	apps, err := c.GetApps(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		if app.Namespace == nsID {
+			report.Apps++
+		}
+	}
+	return report, nil
+}
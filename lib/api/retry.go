@@ -0,0 +1,142 @@
+package goterraapi
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy defines how failed idempotent requests are retried
+//
+// Only GET requests and requests marked idempotent are replayed, on network
+// errors or when server answers 429, 502, 503 or 504
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, 1 or less disables retries
+	MaxAttempts int
+	// BaseDelay is the delay before first retry, doubled at each attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay (Retry-After answers are honored as is)
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used by new clients
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+	}
+}
+
+type idempotentKey struct{}
+
+// idempotent marks requests sent with returned context as safe to replay
+func idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// isReplayable checks if request can be sent again without side effects
+func isReplayable(req *http.Request) bool {
+	if req.Method == "GET" || req.Method == "HEAD" {
+		return true
+	}
+	marked, _ := req.Context().Value(idempotentKey{}).(bool)
+	return marked && (req.Body == nil || req.GetBody != nil)
+}
+
+// isRetryableStatus checks if answer status code is a transient failure
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before retry number attempt (starting at 1), with jitter
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay = delay * 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// Equal jitter: half fixed, half random
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// retryAfter parses Retry-After header (seconds or http date), 0 if absent or invalid
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// send sends request, retrying replayable ones according to client retry policy
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	maxAttempts := c.Retry.MaxAttempts
+	if maxAttempts < 1 || !isReplayable(req) {
+		maxAttempts = 1
+	}
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		resp, respErr := httpClient.Do(req)
+		if respErr != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+		} else if !isRetryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if attempt >= maxAttempts {
+			return resp, respErr
+		}
+
+		delay := c.Retry.backoff(attempt)
+		if resp != nil {
+			if wait := retryAfter(resp); wait > delay {
+				delay = wait
+			}
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxErrorBody))
+			resp.Body.Close()
+		}
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, bodyErr
+			}
+			req.Body = body
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
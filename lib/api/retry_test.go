@@ -0,0 +1,173 @@
+package goterraapi
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+	terraUser "github.com/osallou/goterra-lib/lib/user"
+)
+
+// countingServer answers 503 to the first failures requests of each method, counting requests per method
+//
+// GET requests other than namespace list always succeed, they are run dependencies
+type countingServer struct {
+	mu       sync.Mutex
+	failures int
+	// retryAfter is the Retry-After header of failed answers, if set
+	retryAfter string
+	requests   map[string]int
+	bodies     []string
+}
+
+func (s *countingServer) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	s.mu.Lock()
+	s.requests[r.Method]++
+	count := s.requests[r.Method]
+	s.bodies = append(s.bodies, string(body))
+	s.mu.Unlock()
+	if r.Method == "GET" && r.URL.Path != "/deploy/ns" {
+		// Run dependencies
+		w.Write([]byte(`{}`))
+		return
+	}
+	if count <= s.failures {
+		if s.retryAfter != "" {
+			w.Header().Set("Retry-After", s.retryAfter)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		w.Write([]byte(`{"ns": [{"name": "lab"}]}`))
+	case "POST":
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"run": {"id": "5e8c1d2a9b1e8a0001000001"}}`))
+	default:
+		w.Write([]byte(`{}`))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	s := &countingServer{failures: 1, retryAfter: "1", requests: make(map[string]int)}
+	c, server := newTestClient(s.handle)
+	defer server.Close()
+	start := time.Now()
+	namespaces, err := c.GetNamespaces(context.Background(), false)
+	if err != nil || len(namespaces) != 1 {
+		t.Fatalf("expected namespaces after retry, got %v %v", namespaces, err)
+	}
+	if s.requests["GET"] != 2 {
+		t.Errorf("expected 2 attempts, got %d", s.requests["GET"])
+	}
+	// Retry-After is honored over retry policy max delay
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected retry after 1s, got %s", elapsed)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	s := &countingServer{failures: 10, requests: make(map[string]int)}
+	c, server := newTestClient(s.handle)
+	defer server.Close()
+	_, err := c.GetNamespaces(context.Background(), false)
+	if StatusCode(err) != http.StatusServiceUnavailable {
+		t.Errorf("expected a 503 error, got %v", err)
+	}
+	if s.requests["GET"] != c.Retry.MaxAttempts {
+		t.Errorf("expected %d attempts, got %d", c.Retry.MaxAttempts, s.requests["GET"])
+	}
+
+	s.requests = make(map[string]int)
+	c.Retry.MaxAttempts = 1
+	c.GetNamespaces(context.Background(), false)
+	if s.requests["GET"] != 1 {
+		t.Errorf("expected no retry, got %d attempts", s.requests["GET"])
+	}
+}
+
+func TestRetryIdempotentBody(t *testing.T) {
+	s := &countingServer{failures: 2, requests: make(map[string]int)}
+	c, server := newTestClient(s.handle)
+	defer server.Close()
+	ns := &terraModel.NSData{ID: primitive.NewObjectID(), Name: "lab"}
+	if err := c.UpdateNamespace(context.Background(), ns); err != nil {
+		t.Fatal(err)
+	}
+	if s.requests["PUT"] != 3 {
+		t.Fatalf("expected 3 attempts, got %d", s.requests["PUT"])
+	}
+	for _, body := range s.bodies {
+		if body != s.bodies[0] || body == "" {
+			t.Errorf("expected replayed body %s, got %s", s.bodies[0], body)
+		}
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goterra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	params := filepath.Join(dir, "params.yaml")
+	if err := ioutil.WriteFile(params, []byte("params:\n  flavor: m1.small\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		call   func(c *Client) error
+	}{
+		{"create user", "POST", func(c *Client) error {
+			return c.CreateUser(context.Background(), &terraUser.User{UID: "alice"})
+		}},
+		{"start run", "POST", func(c *Client) error {
+			_, err := c.StartRun(context.Background(), "run", "ns", "ep", "app", params, false)
+			return err
+		}},
+		{"update recipe", "PUT", func(c *Client) error {
+			_, err := c.UpdateRecipe(context.Background(), "ns", &terraModel.Recipe{ID: primitive.NewObjectID()})
+			return err
+		}},
+		{"update template", "PUT", func(c *Client) error {
+			_, err := c.UpdateTemplate(context.Background(), "ns", &terraModel.Template{ID: primitive.NewObjectID()})
+			return err
+		}},
+	}
+	for _, test := range tests {
+		s := &countingServer{failures: 10, retryAfter: "0", requests: make(map[string]int)}
+		c, server := newTestClient(s.handle)
+		err := test.call(c)
+		server.Close()
+		if StatusCode(err) != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected a 503 error, got %v", test.name, err)
+		}
+		if s.requests[test.method] != 1 {
+			t.Errorf("%s: expected a single %s, got %d", test.name, test.method, s.requests[test.method])
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for attempt, max := range []time.Duration{100, 200, 300, 300} {
+		max = max * time.Millisecond
+		for i := 0; i < 20; i++ {
+			if delay := policy.backoff(attempt + 1); delay < max/2 || delay > max {
+				t.Errorf("attempt %d: expected delay between %s and %s, got %s", attempt+1, max/2, max, delay)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package goterraapi
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	terraOutput "github.com/osallou/goterra-cli/lib/output"
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+)
+
+// recipeMatches returns recipe fields containing term, case insensitive
+func recipeMatches(recipe terraModel.Recipe, term string) []string {
+	term = strings.ToLower(term)
+	var matches []string
+	if strings.Contains(strings.ToLower(recipe.Name), term) {
+		matches = append(matches, "name")
+	}
+	if strings.Contains(strings.ToLower(recipe.Description), term) {
+		matches = append(matches, "description")
+	}
+	for _, tag := range recipe.Tags {
+		if strings.Contains(strings.ToLower(tag), term) {
+			matches = append(matches, "tags")
+			break
+		}
+	}
+	return matches
+}
+
+// SearchRecipes searches term in name, description and tags of public recipes and recipes of namespaces nsIDs
+//
+// Hits are sorted by name, with namespace name if user can read namespace
+func (c *Client) SearchRecipes(ctx context.Context, term string, nsIDs []string) ([]terraOutput.RecipeHit, error) {
+	namespaces, err := c.GetNamespaces(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	nsNames := make(map[string]string, len(namespaces))
+	for _, ns := range namespaces {
+		nsNames[ns.ID.Hex()] = ns.Name
+	}
+
+	hits := make([]terraOutput.RecipeHit, 0)
+	seen := make(map[string]bool)
+	// Empty id for public recipes
+	for _, nsID := range append([]string{""}, nsIDs...) {
+		recipes, err := c.GetRecipes(ctx, nsID)
+		if err != nil {
+			return nil, err
+		}
+		for _, recipe := range recipes {
+			if seen[recipe.ID.Hex()] {
+				continue
+			}
+			seen[recipe.ID.Hex()] = true
+			if matches := recipeMatches(recipe, term); len(matches) > 0 {
+				hits = append(hits, terraOutput.RecipeHit{Recipe: recipe, NamespaceName: nsNames[recipe.Namespace], Matches: matches})
+			}
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return strings.ToLower(hits[i].Name) < strings.ToLower(hits[j].Name) })
+	return hits, nil
+}
+
+// ListRecipeSearch displays recipes matching term
+func (c *Client) ListRecipeSearch(ctx context.Context, printer *terraOutput.Printer, term string, nsIDs []string) error {
+	hits, err := c.SearchRecipes(ctx, term, nsIDs)
+	if err != nil {
+		return err
+	}
+	return printer.PrintList(terraOutput.RecipeHits, hits)
+}
@@ -0,0 +1,126 @@
+package goterraapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	terraOutput "github.com/osallou/goterra-cli/lib/output"
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+)
+
+// treeBuilder resolves references of a namespace objects, fetched recipes are cached
+type treeBuilder struct {
+	client  *Client
+	nsID    string
+	recipes map[string]*terraModel.Recipe
+}
+
+func newTreeBuilder(c *Client, nsID string) *treeBuilder {
+	return &treeBuilder{client: c, nsID: nsID, recipes: make(map[string]*terraModel.Recipe)}
+}
+
+// checkNamespace flags objects of an other namespace than tree namespace
+func (b *treeBuilder) checkNamespace(node *terraOutput.TreeNode, public bool) {
+	if node.Namespace == "" || node.Namespace == b.nsID {
+		return
+	}
+	problem := fmt.Sprintf("namespace %s", node.Namespace)
+	if !public {
+		problem += " (not public)"
+	}
+	node.Problems = append(node.Problems, problem)
+}
+
+// missing returns a node for a reference to a missing object
+func missing(kind string, id string, label string) *terraOutput.TreeNode {
+	return &terraOutput.TreeNode{Kind: kind, ID: id, Name: "?", Label: label, Problems: []string{"missing"}}
+}
+
+// recipeNode returns the node of recipe id with its parents, path contains recipes already in branch
+func (b *treeBuilder) recipeNode(ctx context.Context, id string, label string, path map[string]bool) (*terraOutput.TreeNode, error) {
+	recipe, ok := b.recipes[id]
+	if !ok {
+		var err error
+		recipe, err = b.client.GetRecipe(ctx, b.nsID, id)
+		if IsNotFound(err) {
+			recipe = nil
+		} else if err != nil {
+			return nil, err
+		}
+		b.recipes[id] = recipe
+	}
+	if recipe == nil {
+		return missing("recipe", id, label), nil
+	}
+	node := &terraOutput.TreeNode{Kind: "recipe", ID: id, Name: recipe.Name, Namespace: recipe.Namespace, Label: label}
+	b.checkNamespace(node, recipe.Public)
+	if recipe.ParentRecipe == "" {
+		return node, nil
+	}
+	if path[id] {
+		node.Problems = append(node.Problems, "circular parent reference")
+		return node, nil
+	}
+	path[id] = true
+	defer delete(path, id)
+	parent, err := b.recipeNode(ctx, recipe.ParentRecipe, "parent", path)
+	if err != nil {
+		return nil, err
+	}
+	node.Children = append(node.Children, parent)
+	return node, nil
+}
+
+// RecipeTree returns recipe id and its parent recipes as a tree
+func (c *Client) RecipeTree(ctx context.Context, nsID string, id string) (*terraOutput.TreeNode, error) {
+	return newTreeBuilder(c, nsID).recipeNode(ctx, id, "", make(map[string]bool))
+}
+
+// AppTree returns application id, its template and recipes, as a tree
+//
+// Template recipes are children of template node, labelled with their template resource name
+func (c *Client) AppTree(ctx context.Context, nsID string, id string) (*terraOutput.TreeNode, error) {
+	app, err := c.GetApp(ctx, nsID, id)
+	if err != nil {
+		return nil, err
+	}
+	b := newTreeBuilder(c, nsID)
+	root := &terraOutput.TreeNode{Kind: "app", ID: id, Name: app.Name, Namespace: app.Namespace}
+
+	if app.Template != "" {
+		var templateNode *terraOutput.TreeNode
+		template, err := c.GetTemplate(ctx, nsID, app.Template)
+		if IsNotFound(err) {
+			templateNode = missing("template", app.Template, "")
+		} else if err != nil {
+			return nil, err
+		} else {
+			templateNode = &terraOutput.TreeNode{Kind: "template", ID: app.Template, Name: template.Name, Namespace: template.Namespace}
+			b.checkNamespace(templateNode, template.Public)
+		}
+		keys := make([]string, 0, len(app.TemplateRecipes))
+		for key := range app.TemplateRecipes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			for _, recipeID := range app.TemplateRecipes[key] {
+				recipeNode, err := b.recipeNode(ctx, recipeID, key, make(map[string]bool))
+				if err != nil {
+					return nil, err
+				}
+				templateNode.Children = append(templateNode.Children, recipeNode)
+			}
+		}
+		root.Children = append(root.Children, templateNode)
+	}
+	for _, recipeID := range app.Recipes {
+		recipeNode, err := b.recipeNode(ctx, recipeID, "", make(map[string]bool))
+		if err != nil {
+			return nil, err
+		}
+		root.Children = append(root.Children, recipeNode)
+	}
+	return root, nil
+}
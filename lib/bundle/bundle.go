@@ -0,0 +1,167 @@
+package goterrabundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+)
+
+// Version is the bundle format version
+const Version = 1
+
+// Bundle is a portable copy of a namespace endpoints, recipes, templates and applications
+//
+// Objects keep their source ids, which are used to rewrite references on import
+type Bundle struct {
+	Manifest  Manifest
+	Endpoints []terraModel.EndPoint
+	Recipes   []terraModel.Recipe
+	Templates []terraModel.Template
+	Apps      []terraModel.Application
+}
+
+// Manifest describes the bundle origin
+type Manifest struct {
+	Version int `json:"version"`
+	// Source is the Goterra server URL
+	Source        string `json:"source"`
+	Namespace     string `json:"namespace"`
+	NamespaceName string `json:"namespace_name"`
+	// Exported is the export unix timestamp
+	Exported int64 `json:"exported"`
+}
+
+// Bundle archive file names
+const (
+	manifestFile  = "manifest.json"
+	endpointsFile = "endpoints.json"
+	recipesFile   = "recipes.json"
+	templatesFile = "templates.json"
+	appsFile      = "apps.json"
+)
+
+// New creates an empty bundle for namespace
+func New(source string, ns terraModel.NSData) *Bundle {
+	return &Bundle{
+		Manifest: Manifest{
+			Version:       Version,
+			Source:        source,
+			Namespace:     ns.ID.Hex(),
+			NamespaceName: ns.Name,
+			Exported:      time.Now().Unix(),
+		},
+	}
+}
+
+// Write saves bundle as a tar.gz archive of json files
+func (b *Bundle) Write(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writeErr := b.write(file)
+	closeErr := file.Close()
+	if writeErr != nil {
+		os.Remove(path)
+		return writeErr
+	}
+	return closeErr
+}
+
+func (b *Bundle) write(out io.Writer) error {
+	gzipWriter := gzip.NewWriter(out)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, entry := range []struct {
+		name string
+		data interface{}
+	}{
+		{manifestFile, b.Manifest},
+		{endpointsFile, b.Endpoints},
+		{recipesFile, b.Recipes},
+		{templatesFile, b.Templates},
+		{appsFile, b.Apps},
+	} {
+		jsonData, err := json.MarshalIndent(entry.data, "", "  ")
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{
+			Name:    entry.name,
+			Mode:    0644,
+			Size:    int64(len(jsonData)),
+			ModTime: time.Unix(b.Manifest.Exported, 0),
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tarWriter.Write(jsonData); err != nil {
+			return err
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// Read loads a bundle archive
+func Read(path string) (*Bundle, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle %s: %s", path, err)
+	}
+	b := &Bundle{}
+	hasManifest := false
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle %s: %s", path, err)
+		}
+		var target interface{}
+		switch header.Name {
+		case manifestFile:
+			target = &b.Manifest
+			hasManifest = true
+		case endpointsFile:
+			target = &b.Endpoints
+		case recipesFile:
+			target = &b.Recipes
+		case templatesFile:
+			target = &b.Templates
+		case appsFile:
+			target = &b.Apps
+		default:
+			continue
+		}
+		jsonData, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle %s: %s", path, err)
+		}
+		if err := json.Unmarshal(jsonData, target); err != nil {
+			return nil, fmt.Errorf("invalid bundle %s, %s: %s", path, header.Name, err)
+		}
+	}
+	if !hasManifest {
+		return nil, fmt.Errorf("invalid bundle %s: missing %s", path, manifestFile)
+	}
+	if b.Manifest.Version > Version {
+		return nil, fmt.Errorf("bundle %s version %d is not supported, upgrade goterra", path, b.Manifest.Version)
+	}
+	return b, nil
+}
@@ -0,0 +1,130 @@
+package goterraconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Profile defines connection settings to a Goterra server
+type Profile struct {
+	URL string `yaml:"url,omitempty"`
+	// APIKey is the user api key
+	APIKey string `yaml:"apikey,omitempty"`
+	// APIKeyCommand is a shell command printing the api key, used if APIKey is empty
+	APIKeyCommand string `yaml:"apikey_command,omitempty"`
+	// Namespace is the default namespace id for namespace scoped commands
+	Namespace string `yaml:"namespace,omitempty"`
+	// Output is the default output format
+	Output string `yaml:"output,omitempty"`
+}
+
+// Config is the content of the configuration file
+type Config struct {
+	// DefaultProfile is the profile used when none is selected
+	DefaultProfile string             `yaml:"default,omitempty"`
+	Profiles       map[string]Profile `yaml:"profiles"`
+}
+
+// DefaultPath returns the configuration file path, GOT_CONFIG if set or ~/.config/goterra/config.yaml
+func DefaultPath() string {
+	if path := os.Getenv("GOT_CONFIG"); path != "" {
+		return path
+	}
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home := os.Getenv("HOME")
+		if runtime.GOOS == "windows" && home == "" {
+			home = os.Getenv("USERPROFILE")
+		}
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, "goterra", "config.yaml")
+}
+
+// Load reads configuration file, a missing file gives an empty configuration
+func Load(path string) (*Config, error) {
+	config := &Config{Profiles: make(map[string]Profile)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if yamlErr := yaml.Unmarshal(data, config); yamlErr != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %s", path, yamlErr)
+	}
+	if config.Profiles == nil {
+		config.Profiles = make(map[string]Profile)
+	}
+	return config, nil
+}
+
+// Profile returns the named profile, or the default one if name is empty
+//
+// An empty profile is returned if no name is given and there is no default profile
+func (c *Config) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %s, available profiles: %s", name, strings.Join(c.ProfileNames(), ","))
+	}
+	return profile, nil
+}
+
+// ProfileNames returns sorted profile names
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveAPIKey returns the profile api key, running APIKeyCommand if needed
+func (p Profile) ResolveAPIKey() (string, error) {
+	if p.APIKey != "" || p.APIKeyCommand == "" {
+		return p.APIKey, nil
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", p.APIKeyCommand)
+	} else {
+		cmd = exec.Command("sh", "-c", p.APIKeyCommand)
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("apikey command failed: %s", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Save writes configuration file, only readable by user as it may contain api keys
+func (c *Config) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if dirErr := os.MkdirAll(filepath.Dir(path), 0700); dirErr != nil {
+		return dirErr
+	}
+	if writeErr := ioutil.WriteFile(path, data, 0600); writeErr != nil {
+		return writeErr
+	}
+	return os.Chmod(path, 0600)
+}
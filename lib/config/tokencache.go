@@ -0,0 +1,180 @@
+package goterraconfig
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// tokenExpiryMargin is the minimal remaining validity for a cached token to be used
+const tokenExpiryMargin = time.Minute
+
+// CachedToken is an authentication token stored in cache
+type CachedToken struct {
+	Token string `json:"token"`
+	// Expiry is the token expiration date, zero if unknown
+	Expiry time.Time `json:"expiry"`
+}
+
+// TokenCache stores authentication tokens in a file only readable by user
+type TokenCache struct {
+	Path string
+}
+
+// DefaultTokenCachePath returns the token cache file path in user cache directory
+func DefaultTokenCachePath() string {
+	cacheDir := os.Getenv("XDG_CACHE_HOME")
+	if cacheDir == "" {
+		home := os.Getenv("HOME")
+		switch runtime.GOOS {
+		case "windows":
+			cacheDir = os.Getenv("LocalAppData")
+		case "darwin":
+			cacheDir = filepath.Join(home, "Library", "Caches")
+		default:
+			cacheDir = filepath.Join(home, ".cache")
+		}
+	}
+	return filepath.Join(cacheDir, "goterra", "tokens.json")
+}
+
+// NewTokenCache creates a token cache stored in path
+func NewTokenCache(path string) *TokenCache {
+	return &TokenCache{Path: path}
+}
+
+// TokenKey returns the cache key for a profile, server url and api key
+//
+// Only a hash of the api key is used, so that switching keys does not reuse tokens
+func TokenKey(profile string, url string, apiKey string) string {
+	keyHash := sha256.Sum256([]byte(apiKey))
+	return fmt.Sprintf("%s%x", tokenKeyPrefix(profile, url), keyHash[:8])
+}
+
+// tokenKeyPrefix returns the common prefix of profile and server url cache keys
+func tokenKeyPrefix(profile string, url string) string {
+	return fmt.Sprintf("%s@%s#", profile, url)
+}
+
+func (tc *TokenCache) load() (map[string]CachedToken, error) {
+	tokens := make(map[string]CachedToken)
+	data, err := ioutil.ReadFile(tc.Path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if json.Unmarshal(data, &tokens) != nil {
+		// Corrupted cache is ignored, it will be overwritten
+		return make(map[string]CachedToken), nil
+	}
+	return tokens, nil
+}
+
+func (tc *TokenCache) save(tokens map[string]CachedToken) error {
+	if len(tokens) == 0 {
+		err := os.Remove(tc.Path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	if dirErr := os.MkdirAll(filepath.Dir(tc.Path), 0700); dirErr != nil {
+		return dirErr
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(tc.Path), ".tokens")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := tmpFile.Chmod(0600); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), tc.Path)
+}
+
+// Get returns the cached token for key if it is not about to expire
+func (tc *TokenCache) Get(key string) (string, bool) {
+	tokens, err := tc.load()
+	if err != nil {
+		return "", false
+	}
+	cached, ok := tokens[key]
+	if !ok || cached.Token == "" {
+		return "", false
+	}
+	if !cached.Expiry.IsZero() && time.Now().Add(tokenExpiryMargin).After(cached.Expiry) {
+		return "", false
+	}
+	return cached.Token, true
+}
+
+// Put stores a token for key, expired tokens of other keys are purged
+func (tc *TokenCache) Put(key string, token string, expiry time.Time) error {
+	tokens, err := tc.load()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for otherKey, cached := range tokens {
+		if !cached.Expiry.IsZero() && now.After(cached.Expiry) {
+			delete(tokens, otherKey)
+		}
+	}
+	tokens[key] = CachedToken{Token: token, Expiry: expiry}
+	return tc.save(tokens)
+}
+
+// Delete removes token for key from cache
+func (tc *TokenCache) Delete(key string) error {
+	tokens, err := tc.load()
+	if err != nil {
+		return err
+	}
+	delete(tokens, key)
+	return tc.save(tokens)
+}
+
+// DeleteServer removes tokens of profile for server url, whatever their api key, and returns their count
+func (tc *TokenCache) DeleteServer(profile string, url string) (int, error) {
+	tokens, err := tc.load()
+	if err != nil {
+		return 0, err
+	}
+	prefix := tokenKeyPrefix(profile, url)
+	removed := 0
+	for key := range tokens {
+		if strings.HasPrefix(key, prefix) {
+			delete(tokens, key)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, tc.save(tokens)
+}
+
+// Clear removes all cached tokens
+func (tc *TokenCache) Clear() error {
+	return tc.save(nil)
+}
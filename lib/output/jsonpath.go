@@ -0,0 +1,275 @@
+package goterraoutput
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a parsed jsonpath output template
+//
+// Supported syntax is a subset of kubectl jsonpath: text with {expressions},
+// where expression is a path like {.owners[0]}, {[*].name} or {$.inputs.key},
+// {range PATH}...{end} loops and {"quoted text"} literals
+type JSONPath struct {
+	nodes []jsonPathNode
+}
+
+type jsonPathNode struct {
+	text     string
+	path     []pathStep
+	isPath   bool
+	children []jsonPathNode // range body
+	isRange  bool
+}
+
+type pathStep struct {
+	field    string
+	index    int
+	wildcard bool
+	isIndex  bool
+}
+
+// ParseJSONPath parses a jsonpath template
+func ParseJSONPath(template string) (*JSONPath, error) {
+	tokens, err := tokenizeJSONPath(template)
+	if err != nil {
+		return nil, err
+	}
+	nodes, _, err := parseJSONPathNodes(tokens, false)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONPath{nodes: nodes}, nil
+}
+
+type jsonPathToken struct {
+	text   string
+	isExpr bool
+}
+
+func tokenizeJSONPath(template string) ([]jsonPathToken, error) {
+	var tokens []jsonPathToken
+	for len(template) > 0 {
+		start := strings.Index(template, "{")
+		if start < 0 {
+			tokens = append(tokens, jsonPathToken{text: template})
+			break
+		}
+		if start > 0 {
+			tokens = append(tokens, jsonPathToken{text: template[:start]})
+		}
+		end := matchingBracket(template[start:], '{', '}')
+		if end < 0 {
+			return nil, fmt.Errorf("jsonpath: unclosed { in %s", template)
+		}
+		tokens = append(tokens, jsonPathToken{text: strings.TrimSpace(template[start+1 : start+end]), isExpr: true})
+		template = template[start+end+1:]
+	}
+	return tokens, nil
+}
+
+// matchingBracket returns the index of the bracket closing expr[0], -1 if none
+//
+// Brackets in quoted strings, '...' or "..." with \ escapes, are ignored
+func matchingBracket(expr string, open byte, close byte) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == open:
+			depth++
+		case c == close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parseJSONPathNodes(tokens []jsonPathToken, inRange bool) ([]jsonPathNode, []jsonPathToken, error) {
+	var nodes []jsonPathNode
+	for len(tokens) > 0 {
+		token := tokens[0]
+		tokens = tokens[1:]
+		switch {
+		case !token.isExpr:
+			nodes = append(nodes, jsonPathNode{text: token.text})
+		case token.text == "end":
+			if !inRange {
+				return nil, nil, fmt.Errorf("jsonpath: {end} without {range}")
+			}
+			return nodes, tokens, nil
+		case strings.HasPrefix(token.text, "range "):
+			path, err := parsePath(strings.TrimSpace(strings.TrimPrefix(token.text, "range ")))
+			if err != nil {
+				return nil, nil, err
+			}
+			children, rest, err := parseJSONPathNodes(tokens, true)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, jsonPathNode{path: path, isRange: true, children: children})
+			tokens = rest
+		case strings.HasPrefix(token.text, "\""):
+			literal, err := strconv.Unquote(token.text)
+			if err != nil {
+				return nil, nil, fmt.Errorf("jsonpath: invalid literal %s", token.text)
+			}
+			nodes = append(nodes, jsonPathNode{text: literal})
+		default:
+			path, err := parsePath(token.text)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, jsonPathNode{path: path, isPath: true})
+		}
+	}
+	if inRange {
+		return nil, nil, fmt.Errorf("jsonpath: {range} without {end}")
+	}
+	return nodes, nil, nil
+}
+
+// parsePath parses expressions like $.a.b[0], .a[*].b or ['a'].b
+func parsePath(expr string) ([]pathStep, error) {
+	expr = strings.TrimPrefix(expr, "$")
+	var steps []pathStep
+	for len(expr) > 0 {
+		switch expr[0] {
+		case '.':
+			expr = expr[1:]
+			end := strings.IndexAny(expr, ".[")
+			if end < 0 {
+				end = len(expr)
+			}
+			field := expr[:end]
+			expr = expr[end:]
+			if field == "" {
+				continue
+			}
+			if field == "*" {
+				steps = append(steps, pathStep{wildcard: true})
+			} else {
+				steps = append(steps, pathStep{field: field})
+			}
+		case '[':
+			end := matchingBracket(expr, '[', ']')
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath: unclosed [ in %s", expr)
+			}
+			selector := strings.TrimSpace(expr[1:end])
+			expr = expr[end+1:]
+			switch {
+			case selector == "*":
+				steps = append(steps, pathStep{wildcard: true})
+			case strings.HasPrefix(selector, "'") || strings.HasPrefix(selector, "\""):
+				steps = append(steps, pathStep{field: strings.Trim(selector, "'\"")})
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil {
+					return nil, fmt.Errorf("jsonpath: invalid index %s", selector)
+				}
+				steps = append(steps, pathStep{index: index, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("jsonpath: invalid expression %s", expr)
+		}
+	}
+	return steps, nil
+}
+
+// evalPath returns the values matching path in data
+func evalPath(data interface{}, path []pathStep) []interface{} {
+	current := []interface{}{data}
+	for _, step := range path {
+		var next []interface{}
+		for _, value := range current {
+			switch typed := value.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					keys := make([]string, 0, len(typed))
+					for key := range typed {
+						keys = append(keys, key)
+					}
+					sort.Strings(keys)
+					for _, key := range keys {
+						next = append(next, typed[key])
+					}
+				} else if child, ok := typed[step.field]; ok && !step.isIndex {
+					next = append(next, child)
+				}
+			case []interface{}:
+				if step.wildcard {
+					next = append(next, typed...)
+				} else if step.isIndex {
+					index := step.index
+					if index < 0 {
+						index = len(typed) + index
+					}
+					if index >= 0 && index < len(typed) {
+						next = append(next, typed[index])
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// formatJSONPathValue prints strings as is and other values as json
+func formatJSONPathValue(value interface{}) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case nil:
+		return ""
+	default:
+		data, _ := json.Marshal(typed)
+		return string(data)
+	}
+}
+
+// Execute applies template to data, data is first converted using json field names
+func (j *JSONPath) Execute(data interface{}) (string, error) {
+	generic, err := normalize(data)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	executeJSONPathNodes(&out, j.nodes, generic)
+	return out.String(), nil
+}
+
+func executeJSONPathNodes(out *strings.Builder, nodes []jsonPathNode, data interface{}) {
+	for _, node := range nodes {
+		switch {
+		case node.isRange:
+			for _, value := range evalPath(data, node.path) {
+				executeJSONPathNodes(out, node.children, value)
+			}
+		case node.isPath:
+			var values []string
+			for _, value := range evalPath(data, node.path) {
+				values = append(values, formatJSONPathValue(value))
+			}
+			out.WriteString(strings.Join(values, " "))
+		default:
+			out.WriteString(node.text)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package goterraoutput
+
+import "testing"
+
+var jsonPathData = []interface{}{
+	map[string]interface{}{
+		"id":     "1",
+		"name":   "ns1",
+		"owners": []interface{}{"alice", "bob"},
+		"inputs": map[string]interface{}{"flavor": "m1.small", "a}b": "braces"},
+		"count":  2,
+	},
+	map[string]interface{}{
+		"id":     "2",
+		"name":   "ns2",
+		"owners": []interface{}{"carol"},
+		"inputs": map[string]interface{}{},
+		"count":  0,
+	},
+}
+
+func TestJSONPath(t *testing.T) {
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"{[0].name}", "ns1"},
+		{"{$[0].name}", "ns1"},
+		{"{[0].inputs.flavor}", "m1.small"},
+		{"{[0]['inputs']['flavor']}", "m1.small"},
+		{"{[0].inputs['a}b']}", "braces"},
+		{"{[0].owners[1]}", "bob"},
+		{"{[0].owners[-1]}", "bob"},
+		{"{[0].owners[5]}", ""},
+		{"{[*].name}", "ns1 ns2"},
+		{"{[*].owners[*]}", "alice bob carol"},
+		{"{[0].count}", "2"},
+		{"{[1].inputs}", "{}"},
+		{"{[0].missing}", ""},
+		{"name: {[1].name}!", "name: ns2!"},
+		{"{range [*]}{.id}{\"\\t\"}{.name}{\"\\n\"}{end}", "1\tns1\n2\tns2\n"},
+		{"{range [*]}{range .owners[*]}{$}{\",\"}{end}{end}", "alice,bob,carol,"},
+		{"{range [*]}[{.owners[0]}]{end}", "[alice][carol]"},
+		{"{\"}\"}", "}"},
+		{"{\"{\\\"}\\\"}\"}", "{\"}\"}"},
+		{"no expression", "no expression"},
+	}
+	for _, test := range tests {
+		jsonPath, err := ParseJSONPath(test.template)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.template, err)
+			continue
+		}
+		result, err := jsonPath.Execute(jsonPathData)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.template, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.template, test.expected, result)
+		}
+	}
+}
+
+func TestJSONPathErrors(t *testing.T) {
+	for _, template := range []string{
+		"{.name",
+		"{\"}\"",
+		"{end}",
+		"{range [*]}{.name}",
+		"{\"unterminated}",
+		"{[a]}",
+		"{[0}",
+		"{name}",
+		"{'}'}",
+	} {
+		if _, err := ParseJSONPath(template); err == nil {
+			t.Errorf("%s: expected an error", template)
+		}
+	}
+}
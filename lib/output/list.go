@@ -0,0 +1,253 @@
+package goterraoutput
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListOptions selects, sorts and filters items of lists
+//
+// Columns, sort and filters refer to resource column names and use the displayed column values,
+// except time columns which are compared as times
+type ListOptions struct {
+	// Columns to display in table formats, in order, wide columns included
+	Columns []string
+	// SortBy is the column to sort on, descending if prefixed with -
+	SortBy string
+	// Filters must all match for an item to be displayed
+	Filters []Filter
+}
+
+// filterOperators is ordered so that 2 characters operators are matched first
+var filterOperators = []string{"!=", "!~", "<=", ">=", "=", "~", "<", ">"}
+
+// Filter is a condition on a column value, like status=deploy_failed, start>2026-01-01 or name~^test-
+//
+// Operators are = and != for equality, ~ and !~ for regular expressions, <, <=, > and >= for comparisons.
+// Comparisons are numeric if both values are numbers, else alphabetical.
+// On time columns, comparisons use dates like 2026-01-01, 2026-01-01 12:00 or RFC3339 and never match unset times
+type Filter struct {
+	Column   string
+	Operator string
+	Value    string
+
+	regexp *regexp.Regexp
+}
+
+// ParseFilter parses a COLUMN OPERATOR VALUE filter expression
+func ParseFilter(expr string) (Filter, error) {
+	nameEnd := strings.IndexFunc(expr, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_')
+	})
+	if nameEnd <= 0 {
+		return Filter{}, fmt.Errorf("invalid filter %s, expecting COLUMN OPERATOR VALUE", expr)
+	}
+	filter := Filter{Column: strings.ToLower(expr[:nameEnd])}
+	for _, operator := range filterOperators {
+		if strings.HasPrefix(expr[nameEnd:], operator) {
+			filter.Operator = operator
+			filter.Value = expr[nameEnd+len(operator):]
+			break
+		}
+	}
+	if filter.Operator == "" {
+		return Filter{}, fmt.Errorf("invalid filter %s, operator must be one of %s", expr, strings.Join(filterOperators, " "))
+	}
+	if filter.Operator == "~" || filter.Operator == "!~" {
+		re, reErr := regexp.Compile(filter.Value)
+		if reErr != nil {
+			return Filter{}, fmt.Errorf("invalid filter %s: %s", expr, reErr)
+		}
+		filter.regexp = re
+	}
+	return filter, nil
+}
+
+// String returns the filter expression
+func (f Filter) String() string {
+	return f.Column + f.Operator + f.Value
+}
+
+// Match checks if value matches the filter
+func (f Filter) Match(value string) bool {
+	switch f.Operator {
+	case "=":
+		return value == f.Value
+	case "!=":
+		return value != f.Value
+	case "~":
+		return f.regexp.MatchString(value)
+	case "!~":
+		return !f.regexp.MatchString(value)
+	}
+	return f.compared(compareValues(value, f.Value))
+}
+
+// compared checks if the comparison result of a value with the filter value matches the filter
+func (f Filter) compared(cmp int) bool {
+	switch f.Operator {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// isComparison checks if filter operator is an ordered comparison
+func (f Filter) isComparison() bool {
+	return strings.HasPrefix(f.Operator, "<") || strings.HasPrefix(f.Operator, ">")
+}
+
+// filterTimeLayouts are the date formats accepted by filters on time columns, in local time unless specified
+var filterTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// parseFilterTime parses a date of a filter on a time column
+func parseFilterTime(value string) (time.Time, error) {
+	for _, layout := range filterTimeLayouts {
+		if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %s, expecting 2006-01-02, 2006-01-02 15:04 or RFC3339", value)
+}
+
+// matchItem checks if item matches the filter on column
+//
+// Comparisons on time columns use the typed time, unset times do not match
+func (f Filter) matchItem(column Column, item interface{}) bool {
+	if column.Time == nil || !f.isComparison() {
+		return f.Match(column.Value(item))
+	}
+	value := column.Time(item)
+	if value.IsZero() {
+		return false
+	}
+	// Filter value was checked when filters were applied
+	limit, _ := parseFilterTime(f.Value)
+	return f.compared(compareTimes(value, limit))
+}
+
+// compareTimes compares times, unset times first
+func compareTimes(a time.Time, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// compareValues compares numbers numerically and other values alphabetically
+func compareValues(a string, b string) int {
+	aNum, aErr := strconv.ParseFloat(a, 64)
+	bNum, bErr := strconv.ParseFloat(b, 64)
+	if aErr == nil && bErr == nil {
+		switch {
+		case aNum < bNum:
+			return -1
+		case aNum > bNum:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// IsSet checks if some list options are defined
+func (o ListOptions) IsSet() bool {
+	return len(o.Columns) > 0 || o.SortBy != "" || len(o.Filters) > 0
+}
+
+// column returns the resource column with name
+func (r Resource) column(name string) (Column, error) {
+	for _, column := range r.Columns {
+		if column.Name == name {
+			return column, nil
+		}
+	}
+	names := make([]string, len(r.Columns))
+	for i, column := range r.Columns {
+		names[i] = column.Name
+	}
+	return Column{}, fmt.Errorf("unknown %s column %s, expecting one of %s", r.Kind, name, strings.Join(names, ", "))
+}
+
+// selectColumns returns the columns to display, all resource columns if none selected
+func (o ListOptions) selectColumns(resource Resource) ([]Column, error) {
+	if len(o.Columns) == 0 {
+		return resource.Columns, nil
+	}
+	columns := make([]Column, 0, len(o.Columns))
+	for _, name := range o.Columns {
+		column, err := resource.column(strings.ToLower(name))
+		if err != nil {
+			return nil, err
+		}
+		column.Wide = false
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// apply filters and sorts items
+func (o ListOptions) apply(resource Resource, items []interface{}) ([]interface{}, error) {
+	filtered := items
+	if len(o.Filters) > 0 {
+		filterColumns := make([]Column, len(o.Filters))
+		for i, filter := range o.Filters {
+			column, err := resource.column(filter.Column)
+			if err != nil {
+				return nil, err
+			}
+			if column.Time != nil && filter.isComparison() {
+				if _, err := parseFilterTime(filter.Value); err != nil {
+					return nil, fmt.Errorf("invalid filter %s: %s", filter, err)
+				}
+			}
+			filterColumns[i] = column
+		}
+		filtered = make([]interface{}, 0, len(items))
+		for _, item := range items {
+			match := true
+			for i, filter := range o.Filters {
+				if !filter.matchItem(filterColumns[i], item) {
+					match = false
+					break
+				}
+			}
+			if match {
+				filtered = append(filtered, item)
+			}
+		}
+	}
+	if o.SortBy != "" {
+		descending := strings.HasPrefix(o.SortBy, "-")
+		column, err := resource.column(strings.ToLower(strings.TrimPrefix(o.SortBy, "-")))
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(filtered, func(i, j int) bool {
+			var cmp int
+			if column.Time != nil {
+				cmp = compareTimes(column.Time(filtered[i]), column.Time(filtered[j]))
+			} else {
+				cmp = compareValues(column.Value(filtered[i]), column.Value(filtered[j]))
+			}
+			if descending {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+	}
+	return filtered, nil
+}
@@ -0,0 +1,72 @@
+package goterraoutput
+
+import (
+	"testing"
+	"time"
+
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+)
+
+func TestListFilters(t *testing.T) {
+	start := time.Date(2025, 12, 1, 10, 0, 0, 0, time.Local).Unix()
+	end := time.Date(2025, 12, 2, 10, 0, 0, 0, time.Local).Unix()
+	runs := []interface{}{
+		terraModel.Run{Name: "ended", Status: "destroy_success", Start: start, End: end},
+		terraModel.Run{Name: "running", Status: "deploy_success", Start: end},
+		terraModel.Run{Name: "pending", Status: "deploy_pending"},
+	}
+	tests := []struct {
+		filters  []string
+		sortBy   string
+		expected []string
+	}{
+		{[]string{"end<2026-01-01"}, "", []string{"ended"}},
+		{[]string{"end>=2025-12-02 10:00"}, "", []string{"ended"}},
+		{[]string{"end>2025-12-02"}, "", []string{"ended"}},
+		{[]string{"end>2025-12-03"}, "", nil},
+		{[]string{"start>=2025-12-01"}, "-start", []string{"running", "ended"}},
+		{[]string{"end=-"}, "", []string{"running", "pending"}},
+		{[]string{"status~^deploy_", "start<2026-01-01"}, "", []string{"running"}},
+		{nil, "end", []string{"running", "pending", "ended"}},
+		{nil, "-name", []string{"running", "pending", "ended"}},
+	}
+	for _, test := range tests {
+		options := ListOptions{SortBy: test.sortBy}
+		for _, expr := range test.filters {
+			filter, err := ParseFilter(expr)
+			if err != nil {
+				t.Fatalf("%s: %s", expr, err)
+			}
+			options.Filters = append(options.Filters, filter)
+		}
+		items, err := options.apply(Runs, runs)
+		if err != nil {
+			t.Errorf("%v: unexpected error %s", test.filters, err)
+			continue
+		}
+		var names []string
+		for _, item := range items {
+			names = append(names, item.(terraModel.Run).Name)
+		}
+		if len(names) != len(test.expected) {
+			t.Errorf("%v sort %s: expected %v, got %v", test.filters, test.sortBy, test.expected, names)
+			continue
+		}
+		for i := range names {
+			if names[i] != test.expected[i] {
+				t.Errorf("%v sort %s: expected %v, got %v", test.filters, test.sortBy, test.expected, names)
+				break
+			}
+		}
+	}
+}
+
+func TestListFilterInvalidDate(t *testing.T) {
+	filter, err := ParseFilter("end<yesterday")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (ListOptions{Filters: []Filter{filter}}).apply(Runs, []interface{}{terraModel.Run{}}); err == nil {
+		t.Error("expected an invalid date error")
+	}
+}
@@ -0,0 +1,293 @@
+package goterraoutput
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Output formats
+const (
+	FormatTable = "table"
+	FormatWide  = "wide"
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+	FormatName  = "name"
+	FormatCSV   = "csv"
+	// FormatTemplate is a Go text/template applied to each object, e.g. template={{.Name}}
+	FormatTemplate = "template"
+	// FormatJSONPath is a jsonpath expression applied to json data, e.g. jsonpath={[*].id}
+	FormatJSONPath = "jsonpath"
+)
+
+// Formats lists supported output formats
+var Formats = []string{FormatTable, FormatWide, FormatJSON, FormatYAML, FormatName, FormatCSV, FormatTemplate + "=TEMPLATE", FormatJSONPath + "=EXPR"}
+
+// Column is a table column of a resource
+type Column struct {
+	// Name is the column identifier, lowercase
+	Name   string
+	Header string
+	// Wide columns are only displayed in wide format
+	Wide  bool
+	Value func(item interface{}) string
+	// Time returns the column time for filters and sort, zero if not set, only for time columns
+	Time func(item interface{}) time.Time
+}
+
+// Resource describes how to display a kind of object
+type Resource struct {
+	Kind    string
+	Columns []Column
+	// ID returns the object identifier, used by name format
+	ID func(item interface{}) string
+}
+
+// Printer writes resources in selected format
+//
+// If format is empty, lists are displayed as table and single objects as yaml
+type Printer struct {
+	Format string
+	Out    io.Writer
+	// List options apply to PrintList
+	List ListOptions
+
+	template *template.Template
+	jsonPath *JSONPath
+}
+
+// NewPrinter creates a printer after format validation
+//
+// Format can be one of Formats, template and jsonpath formats are followed by = and their expression
+func NewPrinter(format string, out io.Writer) (*Printer, error) {
+	printer := &Printer{Format: format, Out: out}
+	formatArg := ""
+	if sep := strings.Index(format, "="); sep >= 0 {
+		printer.Format = format[:sep]
+		formatArg = format[sep+1:]
+	}
+	switch printer.Format {
+	case "", FormatTable, FormatWide, FormatJSON, FormatYAML, FormatName, FormatCSV:
+		if formatArg != "" {
+			return nil, fmt.Errorf("output format %s does not take an expression", printer.Format)
+		}
+	case FormatTemplate:
+		tmpl, err := ParseTemplate(formatArg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output template: %s", err)
+		}
+		printer.template = tmpl
+	case FormatJSONPath:
+		jsonPath, err := ParseJSONPath(formatArg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output jsonpath: %s", err)
+		}
+		printer.jsonPath = jsonPath
+	default:
+		return nil, fmt.Errorf("unknown output format %s, expecting one of %s", format, strings.Join(Formats, ", "))
+	}
+	return printer, nil
+}
+
+// Items converts a slice of any type to a slice of interface{}
+func Items(slice interface{}) []interface{} {
+	value := reflect.ValueOf(slice)
+	if value.Kind() != reflect.Slice {
+		return []interface{}{slice}
+	}
+	items := make([]interface{}, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		items[i] = value.Index(i).Interface()
+	}
+	return items
+}
+
+// normalize converts data to generic maps and lists using json field names
+//
+// This gives the same field names, and readable object ids, in json and yaml formats
+func normalize(data interface{}) (interface{}, error) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(jsonData, &generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+// PrintList displays a list of objects, items must be a slice
+//
+// Items are filtered and sorted, and table columns selected, according to p.List
+func (p *Printer) PrintList(resource Resource, items interface{}) error {
+	// Empty lists are displayed as [] whatever the way they were built
+	if value := reflect.ValueOf(items); value.Kind() == reflect.Slice && value.IsNil() {
+		items = reflect.MakeSlice(value.Type(), 0, 0).Interface()
+	}
+	format := p.Format
+	if format == "" {
+		format = FormatTable
+	}
+	if !p.List.IsSet() {
+		return p.print(resource, items, Items(items), format)
+	}
+	selected, err := p.List.apply(resource, Items(items))
+	if err != nil {
+		return err
+	}
+	columns, err := p.List.selectColumns(resource)
+	if err != nil {
+		return err
+	}
+	resource.Columns = columns
+	return p.print(resource, selected, selected, format)
+}
+
+// PrintItem displays a single object
+func (p *Printer) PrintItem(resource Resource, item interface{}) error {
+	format := p.Format
+	if format == "" {
+		format = FormatYAML
+	}
+	return p.print(resource, item, []interface{}{item}, format)
+}
+
+// PrintData displays free form data, tables are not supported and fall back to yaml
+func (p *Printer) PrintData(data interface{}) error {
+	switch p.Format {
+	case FormatJSON:
+		return p.printJSON(data)
+	case FormatTemplate:
+		return p.printTemplate([]interface{}{data})
+	case FormatJSONPath:
+		return p.printJSONPath(data)
+	default:
+		return p.printYAML(data)
+	}
+}
+
+func (p *Printer) print(resource Resource, data interface{}, items []interface{}, format string) error {
+	switch format {
+	case FormatJSON:
+		return p.printJSON(data)
+	case FormatYAML:
+		return p.printYAML(data)
+	case FormatName:
+		for _, item := range items {
+			fmt.Fprintln(p.Out, resource.ID(item))
+		}
+		return nil
+	case FormatTemplate:
+		return p.printTemplate(items)
+	case FormatJSONPath:
+		return p.printJSONPath(data)
+	case FormatCSV:
+		return p.printCSV(resource.Columns, items)
+	case FormatWide:
+		return p.printTable(resource.Columns, items, true)
+	default:
+		return p.printTable(resource.Columns, items, false)
+	}
+}
+
+// printTemplate applies template to each item, a new line is added if template does not end with one
+func (p *Printer) printTemplate(items []interface{}) error {
+	for _, item := range items {
+		var out bytes.Buffer
+		if err := p.template.Execute(&out, item); err != nil {
+			return err
+		}
+		if !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+			out.WriteString("\n")
+		}
+		if _, err := p.Out.Write(out.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Printer) printJSONPath(data interface{}) error {
+	out, err := p.jsonPath.Execute(data)
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(out, "\n") {
+		out = out + "\n"
+	}
+	_, err = io.WriteString(p.Out, out)
+	return err
+}
+
+func (p *Printer) printJSON(data interface{}) error {
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(p.Out, "%s\n", jsonData)
+	return nil
+}
+
+func (p *Printer) printYAML(data interface{}) error {
+	generic, err := normalize(data)
+	if err != nil {
+		return err
+	}
+	yamlData, err := yaml.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(p.Out, "%s", yamlData)
+	return nil
+}
+
+// printCSV writes all columns, wide ones included, with column names as header
+func (p *Printer) printCSV(columns []Column, items []interface{}) error {
+	w := csv.NewWriter(p.Out)
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		record[i] = column.Name
+	}
+	w.Write(record)
+	for _, item := range items {
+		for i, column := range columns {
+			record[i] = column.Value(item)
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (p *Printer) printTable(columns []Column, items []interface{}, wide bool) error {
+	w := tabwriter.NewWriter(p.Out, 0, 0, 4, '\t', tabwriter.AlignRight|tabwriter.Debug)
+	var headers []string
+	for _, column := range columns {
+		if column.Wide && !wide {
+			continue
+		}
+		headers = append(headers, column.Header)
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, item := range items {
+		var values []string
+		for _, column := range columns {
+			if column.Wide && !wide {
+				continue
+			}
+			values = append(values, column.Value(item))
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,36 @@
+package goterraoutput
+
+import (
+	"bytes"
+	"testing"
+
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+)
+
+func TestPrintEmptyList(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{FormatJSON, "[]\n"},
+		{FormatYAML, "[]\n"},
+		{FormatJSONPath + "={[*].name}", "\n"},
+		{FormatName, ""},
+	}
+	for _, test := range tests {
+		for _, runs := range [][]terraModel.Run{nil, {}} {
+			var out bytes.Buffer
+			printer, err := NewPrinter(test.format, &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := printer.PrintList(Runs, runs); err != nil {
+				t.Errorf("%s: unexpected error %s", test.format, err)
+				continue
+			}
+			if out.String() != test.expected {
+				t.Errorf("%s: expected %q, got %q", test.format, test.expected, out.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package goterraoutput
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+	terraUser "github.com/osallou/goterra-lib/lib/user"
+)
+
+// FormatTime formats a unix timestamp for tables, "-" if not set
+func FormatTime(timestamp int64) string {
+	if timestamp == 0 {
+		return "-"
+	}
+	return time.Unix(timestamp, 0).Format("2006-01-02 15:04:05")
+}
+
+// unixTime converts a unix timestamp, zero time if not set
+func unixTime(timestamp int64) time.Time {
+	if timestamp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(timestamp, 0)
+}
+
+// Namespaces displays terraModel.NSData
+var Namespaces = Resource{
+	Kind: "namespace",
+	Columns: []Column{
+		{Name: "id", Header: "ID", Value: func(item interface{}) string { return item.(terraModel.NSData).ID.Hex() }},
+		{Name: "name", Header: "Name", Value: func(item interface{}) string { return item.(terraModel.NSData).Name }},
+		{Name: "owners", Header: "Owners", Value: func(item interface{}) string { return strings.Join(item.(terraModel.NSData).Owners, ",") }},
+		{Name: "members", Header: "Members", Wide: true, Value: func(item interface{}) string { return strings.Join(item.(terraModel.NSData).Members, ",") }},
+		{Name: "freeze", Header: "Freeze", Wide: true, Value: func(item interface{}) string { return strconv.FormatBool(item.(terraModel.NSData).Freeze) }},
+	},
+	ID: func(item interface{}) string { return item.(terraModel.NSData).ID.Hex() },
+}
+
+// EndpointItem is an endpoint with user secret status
+type EndpointItem struct {
+	terraModel.EndPoint
+	// HasSecret is nil if unknown
+	HasSecret *bool `json:"has_secret,omitempty"`
+}
+
+// Endpoints displays EndpointItem
+var Endpoints = Resource{
+	Kind: "endpoint",
+	Columns: []Column{
+		{Name: "id", Header: "ID", Value: func(item interface{}) string { return item.(EndpointItem).ID.Hex() }},
+		{Name: "name", Header: "Name", Value: func(item interface{}) string { return item.(EndpointItem).Name }},
+		{Name: "kind", Header: "Kind", Value: func(item interface{}) string { return item.(EndpointItem).Kind }},
+		{Name: "public", Header: "Public", Value: func(item interface{}) string { return strconv.FormatBool(item.(EndpointItem).Public) }},
+		{Name: "namespace", Header: "Namespace", Value: func(item interface{}) string { return item.(EndpointItem).Namespace }},
+		{Name: "secret", Header: "Secret", Value: func(item interface{}) string {
+			if hasSecret := item.(EndpointItem).HasSecret; hasSecret != nil {
+				return strconv.FormatBool(*hasSecret)
+			}
+			return "-"
+		}},
+	},
+	ID: func(item interface{}) string { return item.(EndpointItem).ID.Hex() },
+}
+
+// EndpointDefault is an endpoint default input, a single value is a default value, several values are choices
+type EndpointDefault struct {
+	Input  string   `json:"input"`
+	Values []string `json:"values"`
+}
+
+// EndpointDefaults displays EndpointDefault
+var EndpointDefaults = Resource{
+	Kind: "default",
+	Columns: []Column{
+		{Name: "input", Header: "Input", Value: func(item interface{}) string { return item.(EndpointDefault).Input }},
+		{Name: "default", Header: "Default", Value: func(item interface{}) string {
+			if values := item.(EndpointDefault).Values; len(values) == 1 {
+				return values[0]
+			}
+			return ""
+		}},
+		{Name: "choices", Header: "Choices", Value: func(item interface{}) string {
+			if values := item.(EndpointDefault).Values; len(values) > 1 {
+				return strings.Join(values, ",")
+			}
+			return ""
+		}},
+	},
+	ID: func(item interface{}) string { return item.(EndpointDefault).Input },
+}
+
+// Recipes displays terraModel.Recipe
+var Recipes = Resource{
+	Kind: "recipe",
+	Columns: []Column{
+		{Name: "id", Header: "ID", Value: func(item interface{}) string { return item.(terraModel.Recipe).ID.Hex() }},
+		{Name: "name", Header: "Name", Value: func(item interface{}) string { return item.(terraModel.Recipe).Name }},
+		{Name: "description", Header: "Description", Value: func(item interface{}) string { return item.(terraModel.Recipe).Description }},
+		{Name: "public", Header: "Public", Value: func(item interface{}) string { return strconv.FormatBool(item.(terraModel.Recipe).Public) }},
+		{Name: "namespace", Header: "Namespace", Value: func(item interface{}) string { return item.(terraModel.Recipe).Namespace }},
+		{Name: "parent", Header: "Parent", Wide: true, Value: func(item interface{}) string { return item.(terraModel.Recipe).ParentRecipe }},
+		{Name: "tags", Header: "Tags", Wide: true, Value: func(item interface{}) string { return strings.Join(item.(terraModel.Recipe).Tags, ",") }},
+	},
+	ID: func(item interface{}) string { return item.(terraModel.Recipe).ID.Hex() },
+}
+
+// RecipeHit is a recipe matching a search
+type RecipeHit struct {
+	terraModel.Recipe
+	// NamespaceName is empty if namespace is not readable by user
+	NamespaceName string `json:"namespace_name,omitempty"`
+	// Matches lists fields matching search: name, description or tags
+	Matches []string `json:"matches"`
+}
+
+// RecipeHits displays RecipeHit
+var RecipeHits = Resource{
+	Kind: "recipe",
+	Columns: []Column{
+		{Name: "id", Header: "ID", Value: func(item interface{}) string { return item.(RecipeHit).ID.Hex() }},
+		{Name: "name", Header: "Name", Value: func(item interface{}) string { return item.(RecipeHit).Name }},
+		{Name: "namespace", Header: "Namespace", Value: func(item interface{}) string {
+			hit := item.(RecipeHit)
+			if hit.NamespaceName != "" {
+				return hit.NamespaceName
+			}
+			return hit.Namespace
+		}},
+		{Name: "public", Header: "Public", Value: func(item interface{}) string { return strconv.FormatBool(item.(RecipeHit).Public) }},
+		{Name: "matches", Header: "Matches", Value: func(item interface{}) string { return strings.Join(item.(RecipeHit).Matches, ",") }},
+		{Name: "description", Header: "Description", Wide: true, Value: func(item interface{}) string { return item.(RecipeHit).Description }},
+		{Name: "tags", Header: "Tags", Wide: true, Value: func(item interface{}) string { return strings.Join(item.(RecipeHit).Tags, ",") }},
+	},
+	ID: func(item interface{}) string { return item.(RecipeHit).ID.Hex() },
+}
+
+// Templates displays terraModel.Template
+var Templates = Resource{
+	Kind: "template",
+	Columns: []Column{
+		{Name: "id", Header: "ID", Value: func(item interface{}) string { return item.(terraModel.Template).ID.Hex() }},
+		{Name: "name", Header: "Name", Value: func(item interface{}) string { return item.(terraModel.Template).Name }},
+		{Name: "description", Header: "Description", Value: func(item interface{}) string { return item.(terraModel.Template).Description }},
+		{Name: "public", Header: "Public", Value: func(item interface{}) string { return strconv.FormatBool(item.(terraModel.Template).Public) }},
+		{Name: "namespace", Header: "Namespace", Value: func(item interface{}) string { return item.(terraModel.Template).Namespace }},
+		{Name: "tags", Header: "Tags", Wide: true, Value: func(item interface{}) string { return strings.Join(item.(terraModel.Template).Tags, ",") }},
+	},
+	ID: func(item interface{}) string { return item.(terraModel.Template).ID.Hex() },
+}
+
+// Apps displays terraModel.Application
+var Apps = Resource{
+	Kind: "app",
+	Columns: []Column{
+		{Name: "id", Header: "ID", Value: func(item interface{}) string { return item.(terraModel.Application).ID.Hex() }},
+		{Name: "name", Header: "Name", Value: func(item interface{}) string { return item.(terraModel.Application).Name }},
+		{Name: "description", Header: "Description", Value: func(item interface{}) string { return item.(terraModel.Application).Description }},
+		{Name: "public", Header: "Public", Value: func(item interface{}) string { return strconv.FormatBool(item.(terraModel.Application).Public) }},
+		{Name: "namespace", Header: "Namespace", Value: func(item interface{}) string { return item.(terraModel.Application).Namespace }},
+		{Name: "template", Header: "Template", Wide: true, Value: func(item interface{}) string { return item.(terraModel.Application).Template }},
+		{Name: "recipes", Header: "Recipes", Wide: true, Value: func(item interface{}) string { return strings.Join(item.(terraModel.Application).Recipes, ",") }},
+	},
+	ID: func(item interface{}) string { return item.(terraModel.Application).ID.Hex() },
+}
+
+// Runs displays terraModel.Run
+var Runs = Resource{
+	Kind: "run",
+	Columns: []Column{
+		{Name: "id", Header: "ID", Value: func(item interface{}) string { return item.(terraModel.Run).ID.Hex() }},
+		{Name: "name", Header: "Name", Value: func(item interface{}) string { return item.(terraModel.Run).Name }},
+		{Name: "status", Header: "Status", Value: func(item interface{}) string { return item.(terraModel.Run).Status }},
+		{Name: "start", Header: "Start", Value: func(item interface{}) string { return FormatTime(item.(terraModel.Run).Start) }, Time: func(item interface{}) time.Time { return unixTime(item.(terraModel.Run).Start) }},
+		{Name: "end", Header: "End", Value: func(item interface{}) string { return FormatTime(item.(terraModel.Run).End) }, Time: func(item interface{}) time.Time { return unixTime(item.(terraModel.Run).End) }},
+		{Name: "namespace", Header: "Namespace", Value: func(item interface{}) string { return item.(terraModel.Run).Namespace }},
+		{Name: "endpoint", Header: "Endpoint", Wide: true, Value: func(item interface{}) string { return item.(terraModel.Run).Endpoint }},
+		{Name: "app", Header: "App", Wide: true, Value: func(item interface{}) string { return item.(terraModel.Run).AppID }},
+		{Name: "deployment", Header: "Deployment", Wide: true, Value: func(item interface{}) string { return item.(terraModel.Run).Deployment }},
+	},
+	ID: func(item interface{}) string { return item.(terraModel.Run).ID.Hex() },
+}
+
+// NSReport is the usage of a namespace during a time window
+type NSReport struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Owners    int    `json:"owners"`
+	Members   int    `json:"members"`
+	// Runs is the number of runs active during window
+	Runs       int `json:"runs"`
+	ActiveRuns int `json:"active_runs"`
+	FailedRuns int `json:"failed_runs"`
+	// RunHours is the total duration of runs within window
+	RunHours float64 `json:"run_hours"`
+	// Endpoints is the number of endpoints used by runs
+	Endpoints int `json:"endpoints"`
+	Apps      int `json:"apps"`
+}
+
+// NSReports displays NSReport
+var NSReports = Resource{
+	Kind: "report",
+	Columns: []Column{
+		{Name: "namespace", Header: "Namespace", Value: func(item interface{}) string { return item.(NSReport).Namespace }},
+		{Name: "name", Header: "Name", Value: func(item interface{}) string { return item.(NSReport).Name }},
+		{Name: "owners", Header: "Owners", Wide: true, Value: func(item interface{}) string { return strconv.Itoa(item.(NSReport).Owners) }},
+		{Name: "members", Header: "Members", Value: func(item interface{}) string { return strconv.Itoa(item.(NSReport).Members) }},
+		{Name: "runs", Header: "Runs", Value: func(item interface{}) string { return strconv.Itoa(item.(NSReport).Runs) }},
+		{Name: "active", Header: "Active", Value: func(item interface{}) string { return strconv.Itoa(item.(NSReport).ActiveRuns) }},
+		{Name: "failed", Header: "Failed", Value: func(item interface{}) string { return strconv.Itoa(item.(NSReport).FailedRuns) }},
+		{Name: "hours", Header: "Run hours", Value: func(item interface{}) string { return strconv.FormatFloat(item.(NSReport).RunHours, 'f', 2, 64) }},
+		{Name: "endpoints", Header: "Endpoints", Value: func(item interface{}) string { return strconv.Itoa(item.(NSReport).Endpoints) }},
+		{Name: "apps", Header: "Apps", Value: func(item interface{}) string { return strconv.Itoa(item.(NSReport).Apps) }},
+	},
+	ID: func(item interface{}) string { return item.(NSReport).Namespace },
+}
+
+// Users displays terraUser.User
+var Users = Resource{
+	Kind: "user",
+	Columns: []Column{
+		{Name: "uid", Header: "UID", Value: func(item interface{}) string { return item.(terraUser.User).UID }},
+		{Name: "admin", Header: "Admin", Value: func(item interface{}) string { return strconv.FormatBool(item.(terraUser.User).Admin) }},
+		{Name: "super", Header: "Super user", Value: func(item interface{}) string { return strconv.FormatBool(item.(terraUser.User).SuperUser) }},
+		{Name: "email", Header: "Email", Value: func(item interface{}) string { return item.(terraUser.User).Email }},
+		{Name: "kind", Header: "Kind", Value: func(item interface{}) string { return item.(terraUser.User).Kind }},
+	},
+	ID: func(item interface{}) string { return item.(terraUser.User).UID },
+}
@@ -0,0 +1,60 @@
+package goterraoutput
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateFuncs are the helper functions available in output templates
+//
+// Timestamps are unix timestamps such as Run.Start and Run.End
+var TemplateFuncs = template.FuncMap{
+	// date formats a timestamp as "2006-01-02 15:04:05", "-" if not set
+	"date": FormatTime,
+	// datefmt formats a timestamp with a Go time layout
+	"datefmt": func(layout string, timestamp int64) string {
+		if timestamp == 0 {
+			return ""
+		}
+		return time.Unix(timestamp, 0).Format(layout)
+	},
+	// rfc3339 formats a timestamp as RFC3339
+	"rfc3339": func(timestamp int64) string {
+		if timestamp == 0 {
+			return ""
+		}
+		return time.Unix(timestamp, 0).Format(time.RFC3339)
+	},
+	// since returns the duration elapsed since timestamp
+	"since": func(timestamp int64) string {
+		if timestamp == 0 {
+			return ""
+		}
+		return time.Since(time.Unix(timestamp, 0)).Round(time.Second).String()
+	},
+	// duration returns the duration between start and end, or now if end is not set
+	"duration": func(start int64, end int64) string {
+		if start == 0 {
+			return ""
+		}
+		endTime := time.Now()
+		if end != 0 {
+			endTime = time.Unix(end, 0)
+		}
+		return endTime.Sub(time.Unix(start, 0)).Round(time.Second).String()
+	},
+	"join": func(sep string, values []string) string {
+		return strings.Join(values, sep)
+	},
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+// ParseTemplate parses a Go text/template with TemplateFuncs helpers
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("output").Funcs(TemplateFuncs).Option("missingkey=zero").Parse(text)
+}
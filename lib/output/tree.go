@@ -0,0 +1,99 @@
+package goterraoutput
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Tree formats
+const (
+	TreeText = "text"
+	TreeDot  = "dot"
+)
+
+// TreeNode is an object of a dependency tree (application, template, recipe)
+type TreeNode struct {
+	Kind      string
+	ID        string
+	Name      string
+	Namespace string
+	// Label describes the relation with parent node, e.g. parent or a template resource name
+	Label string
+	// Problems lists reference problems, like missing or cross-namespace objects
+	Problems []string
+	Children []*TreeNode
+}
+
+// String describes node
+func (n *TreeNode) String() string {
+	desc := fmt.Sprintf("%s %s (%s)", n.Kind, n.Name, n.ID)
+	if n.Label != "" {
+		desc = fmt.Sprintf("[%s] %s", n.Label, desc)
+	}
+	if len(n.Problems) > 0 {
+		desc += fmt.Sprintf(" !! %s", strings.Join(n.Problems, ", "))
+	}
+	return desc
+}
+
+// PrintTree displays tree in format text (ascii tree) or dot (Graphviz)
+func PrintTree(out io.Writer, root *TreeNode, format string) error {
+	switch format {
+	case "", TreeText:
+		fmt.Fprintln(out, root)
+		printTreeChildren(out, root, "")
+	case TreeDot:
+		printDot(out, root)
+	default:
+		return fmt.Errorf("unknown tree format %s, expecting %s or %s", format, TreeText, TreeDot)
+	}
+	return nil
+}
+
+func printTreeChildren(out io.Writer, node *TreeNode, prefix string) {
+	for i, child := range node.Children {
+		branch, indent := "|-- ", "|   "
+		if i == len(node.Children)-1 {
+			branch, indent = "`-- ", "    "
+		}
+		fmt.Fprintf(out, "%s%s%s\n", prefix, branch, child)
+		printTreeChildren(out, child, prefix+indent)
+	}
+}
+
+// printDot writes tree as a Graphviz digraph, nodes with problems are red
+//
+// Quoted labels use \n escapes for new lines, which Graphviz understands
+func printDot(out io.Writer, root *TreeNode) {
+	fmt.Fprintln(out, "digraph goterra {")
+	fmt.Fprintln(out, "  node [shape=box];")
+	nodes := make(map[string]bool)
+	edges := make(map[string]bool)
+	var walk func(node *TreeNode)
+	walk = func(node *TreeNode) {
+		if !nodes[node.ID] {
+			nodes[node.ID] = true
+			label := fmt.Sprintf("%s\n%s", node.Kind, node.Name)
+			attrs := ""
+			if len(node.Problems) > 0 {
+				label += "\n" + strings.Join(node.Problems, "\n")
+				attrs = ", color=red"
+			}
+			fmt.Fprintf(out, "  %q [label=%q%s];\n", node.ID, label, attrs)
+		}
+		for _, child := range node.Children {
+			edge := fmt.Sprintf("  %q -> %q", node.ID, child.ID)
+			if child.Label != "" {
+				edge += fmt.Sprintf(" [label=%q]", child.Label)
+			}
+			if !edges[edge] {
+				edges[edge] = true
+				fmt.Fprintf(out, "%s;\n", edge)
+			}
+			walk(child)
+		}
+	}
+	walk(root)
+	fmt.Fprintln(out, "}")
+}
@@ -0,0 +1,85 @@
+package goterraprompt
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// isTerminal checks if file is a character device (a terminal)
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Interactive checks if stdin is a terminal
+func Interactive() bool {
+	return isTerminal(os.Stdin)
+}
+
+// setEcho enables or disables terminal echo of stdin, returns false if not possible
+func setEcho(enabled bool) bool {
+	if runtime.GOOS == "windows" || !isTerminal(os.Stdin) {
+		return false
+	}
+	mode := "-echo"
+	if enabled {
+		mode = "echo"
+	}
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = os.Stdin
+	return cmd.Run() == nil
+}
+
+// Secret asks user for a value without echoing it on terminal
+//
+// Echo is restored if prompt is interrupted, an error is then returned
+func Secret(label string) (string, error) {
+	fmt.Fprintf(os.Stderr, "%s: ", label)
+	if !setEcho(false) {
+		return readLine(label)
+	}
+	defer func() {
+		setEcho(true)
+		fmt.Fprintln(os.Stderr)
+	}()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	type result struct {
+		text string
+		err  error
+	}
+	// Reader is left blocked on interrupt, process is about to exit
+	line := make(chan result, 1)
+	go func() {
+		text, err := readLine(label)
+		line <- result{text, err}
+	}()
+	select {
+	case res := <-line:
+		return res.text, res.err
+	case <-interrupt:
+		return "", fmt.Errorf("failed to read %s: interrupted", label)
+	}
+}
+
+// readLine reads a line from stdin, trailing new line is removed
+func readLine(label string) (string, error) {
+	reader := bufio.NewReader(os.Stdin)
+	text, err := reader.ReadString('\n')
+	if err != nil && text == "" {
+		return "", fmt.Errorf("failed to read %s: %s", label, err)
+	}
+	return strings.TrimRight(text, "\r\n"), nil
+}
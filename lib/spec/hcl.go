@@ -0,0 +1,433 @@
+package goterraspec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TerraformVariable is a variable block of a Terraform file
+type TerraformVariable struct {
+	Name        string
+	Description string
+	// Default is the default value, strings are unquoted, other values are kept as written
+	Default    string
+	HasDefault bool
+}
+
+// HCL token kinds
+const (
+	hclIdent = iota
+	hclString
+	hclHeredoc
+	hclNumber
+	hclPunct
+	hclNewline
+)
+
+type hclToken struct {
+	kind int
+	// text is the token source, value the string or heredoc content
+	text  string
+	value string
+	line  int
+}
+
+// hclLexer splits HCL source in tokens, it only supports what is needed to find variable blocks
+type hclLexer struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (l *hclLexer) errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (l *hclLexer) lex() ([]hclToken, error) {
+	var tokens []hclToken
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		start := l.pos
+		line := l.line
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+			continue
+		case c == '\n':
+			l.pos++
+			l.line++
+			tokens = append(tokens, hclToken{kind: hclNewline, line: line})
+			continue
+		case c == '#' || strings.HasPrefix(l.src[l.pos:], "//"):
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				return nil, l.errorf(line, "unterminated comment")
+			}
+			l.line += strings.Count(l.src[l.pos:l.pos+end+4], "\n")
+			l.pos += end + 4
+			continue
+		case c == '"':
+			value, err := l.readString()
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, hclToken{kind: hclString, text: l.src[start:l.pos], value: value, line: line})
+		case strings.HasPrefix(l.src[l.pos:], "<<"):
+			value, err := l.readHeredoc()
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, hclToken{kind: hclHeredoc, text: l.src[start:l.pos], value: value, line: line})
+		case c >= '0' && c <= '9':
+			for l.pos < len(l.src) && (isIdentChar(l.src[l.pos]) || l.src[l.pos] == '.') {
+				l.pos++
+			}
+			tokens = append(tokens, hclToken{kind: hclNumber, text: l.src[start:l.pos], line: line})
+		case isIdentChar(c):
+			for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+				l.pos++
+			}
+			tokens = append(tokens, hclToken{kind: hclIdent, text: l.src[start:l.pos], line: line})
+		default:
+			l.pos++
+			tokens = append(tokens, hclToken{kind: hclPunct, text: l.src[start:l.pos], line: line})
+		}
+	}
+	return tokens, nil
+}
+
+// readString reads a quoted string, with its ${...} interpolations, and returns its unescaped value
+func (l *hclLexer) readString() (string, error) {
+	line := l.line
+	var value strings.Builder
+	l.pos++
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+			return value.String(), nil
+		case c == '\n':
+			return "", l.errorf(line, "unterminated string")
+		case c == '\\' && l.pos+1 < len(l.src):
+			l.pos += 2
+			switch escaped := l.src[l.pos-1]; escaped {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			case 'r':
+				value.WriteByte('\r')
+			default:
+				value.WriteByte(escaped)
+			}
+		case strings.HasPrefix(l.src[l.pos:], "$${") || strings.HasPrefix(l.src[l.pos:], "%%{"):
+			// Escaped interpolation
+			value.WriteString(l.src[l.pos+1 : l.pos+3])
+			l.pos += 3
+		case (c == '$' || c == '%') && strings.HasPrefix(l.src[l.pos+1:], "{"):
+			// Interpolation or directive, may contain nested strings and braces
+			start := l.pos
+			if err := l.skipInterpolation(line); err != nil {
+				return "", err
+			}
+			value.WriteString(l.src[start:l.pos])
+		default:
+			value.WriteByte(c)
+			l.pos++
+		}
+	}
+	return "", l.errorf(line, "unterminated string")
+}
+
+// skipInterpolation skips a ${...} or %{...} sequence, up to the brace closing its opening brace
+func (l *hclLexer) skipInterpolation(line int) error {
+	l.pos++
+	depth := 0
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			if _, err := l.readString(); err != nil {
+				return err
+			}
+			continue
+		case '\n':
+			return l.errorf(line, "unterminated string interpolation")
+		}
+		l.pos++
+		if c == '{' {
+			depth++
+		} else if c == '}' {
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+	return l.errorf(line, "unterminated string interpolation")
+}
+
+// readHeredoc reads a <<EOF or <<-EOF heredoc and returns its content
+func (l *hclLexer) readHeredoc() (string, error) {
+	line := l.line
+	l.pos += 2
+	indented := false
+	if l.pos < len(l.src) && l.src[l.pos] == '-' {
+		indented = true
+		l.pos++
+	}
+	start := l.pos
+	for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+		l.pos++
+	}
+	marker := l.src[start:l.pos]
+	if marker == "" {
+		return "", l.errorf(line, "missing heredoc marker")
+	}
+	end := strings.IndexByte(l.src[l.pos:], '\n')
+	if end < 0 {
+		return "", l.errorf(line, "unterminated heredoc %s", marker)
+	}
+	l.pos += end + 1
+	l.line++
+	var lines []string
+	for l.pos < len(l.src) {
+		end := strings.IndexByte(l.src[l.pos:], '\n')
+		text := l.src[l.pos:]
+		if end >= 0 {
+			text = l.src[l.pos : l.pos+end]
+		}
+		if strings.TrimSpace(text) == marker {
+			// Closing marker, following new line is a token
+			l.pos += len(text)
+			if indented {
+				lines = unindent(lines)
+			}
+			return strings.Join(lines, "\n"), nil
+		}
+		lines = append(lines, strings.TrimSuffix(text, "\r"))
+		if end < 0 {
+			break
+		}
+		l.pos += end + 1
+		l.line++
+	}
+	return "", l.errorf(line, "unterminated heredoc %s", marker)
+}
+
+// unindent removes the smallest leading spaces of lines
+func unindent(lines []string) []string {
+	indent := -1
+	for _, text := range lines {
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		spaces := len(text) - len(strings.TrimLeft(text, " \t"))
+		if indent < 0 || spaces < indent {
+			indent = spaces
+		}
+	}
+	result := make([]string, len(lines))
+	for i, text := range lines {
+		if len(text) >= indent && indent > 0 {
+			text = text[indent:]
+		}
+		result[i] = text
+	}
+	return result
+}
+
+// hclParser finds top level variable blocks
+type hclParser struct {
+	tokens []hclToken
+	pos    int
+}
+
+func (p *hclParser) next() (hclToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return hclToken{}, false
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	return token, true
+}
+
+func (p *hclParser) skipNewlines() {
+	for p.pos < len(p.tokens) && p.tokens[p.pos].kind == hclNewline {
+		p.pos++
+	}
+}
+
+// closing returns the closing punctuation of a bracket
+func closing(open string) string {
+	switch open {
+	case "{":
+		return "}"
+	case "[":
+		return "]"
+	case "(":
+		return ")"
+	}
+	return ""
+}
+
+// expression reads an attribute expression, up to end of line outside brackets, and returns its tokens
+func (p *hclParser) expression(line int) ([]hclToken, error) {
+	var expr []hclToken
+	var stack []string
+	for p.pos < len(p.tokens) {
+		token := p.tokens[p.pos]
+		if len(stack) == 0 && (token.kind == hclNewline || token.text == "}") {
+			break
+		}
+		p.pos++
+		if token.kind == hclPunct {
+			if close := closing(token.text); close != "" {
+				stack = append(stack, close)
+			} else if token.text == "}" || token.text == "]" || token.text == ")" {
+				if len(stack) == 0 || stack[len(stack)-1] != token.text {
+					return nil, fmt.Errorf("line %d: unexpected %s", token.line, token.text)
+				}
+				stack = stack[:len(stack)-1]
+			}
+		}
+		expr = append(expr, token)
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("line %d: %s not closed", line, stack[len(stack)-1])
+	}
+	if len(expr) == 0 {
+		return nil, fmt.Errorf("line %d: missing value", line)
+	}
+	return expr, nil
+}
+
+// exprValue returns the value of a literal string, or the expression on a single line, without comments
+func exprValue(expr []hclToken) string {
+	if len(expr) == 1 && (expr[0].kind == hclString || expr[0].kind == hclHeredoc) {
+		return expr[0].value
+	}
+	var value strings.Builder
+	previous := ""
+	for _, token := range expr {
+		if token.kind == hclNewline {
+			continue
+		}
+		if previous != "" && previous != "[" && previous != "(" && previous != "." && token.text != "," && token.text != "]" && token.text != ")" && token.text != "." {
+			value.WriteByte(' ')
+		}
+		value.WriteString(token.text)
+		previous = token.text
+	}
+	return value.String()
+}
+
+// body parses a block body up to its closing brace (or end of file at top level)
+//
+// Attributes are returned by name, blocks of this body are given to collect if set
+func (p *hclParser) body(topLevel bool, collect func(blockType string, labels []string, attributes map[string][]hclToken)) (map[string][]hclToken, error) {
+	attributes := make(map[string][]hclToken)
+	for {
+		p.skipNewlines()
+		token, ok := p.next()
+		if !ok {
+			if topLevel {
+				return attributes, nil
+			}
+			return nil, fmt.Errorf("unexpected end of file, block not closed")
+		}
+		if token.text == "}" && !topLevel {
+			return attributes, nil
+		}
+		if token.kind != hclIdent {
+			return nil, fmt.Errorf("line %d: unexpected %s, expecting an attribute or block", token.line, token.text)
+		}
+		var labels []string
+		for {
+			label, ok := p.next()
+			if !ok {
+				return nil, fmt.Errorf("line %d: unexpected end of file after %s", token.line, token.text)
+			}
+			switch {
+			case label.text == "=" && len(labels) == 0:
+				expr, err := p.expression(token.line)
+				if err != nil {
+					return nil, err
+				}
+				attributes[token.text] = expr
+			case label.text == "{":
+				blockAttributes, err := p.body(false, nil)
+				if err != nil {
+					return nil, err
+				}
+				if collect != nil {
+					collect(token.text, labels, blockAttributes)
+				}
+			case label.kind == hclString || label.kind == hclIdent:
+				labels = append(labels, label.value)
+				if label.kind == hclIdent {
+					labels[len(labels)-1] = label.text
+				}
+				continue
+			default:
+				return nil, fmt.Errorf("line %d: unexpected %s after %s", label.line, label.text, token.text)
+			}
+			break
+		}
+	}
+}
+
+// ParseTerraformVariables returns the variable blocks of a Terraform (HCL) file
+func ParseTerraformVariables(src string) ([]TerraformVariable, error) {
+	lexer := &hclLexer{src: src, line: 1}
+	tokens, err := lexer.lex()
+	if err != nil {
+		return nil, err
+	}
+	parser := &hclParser{tokens: tokens}
+	var variables []TerraformVariable
+	_, err = parser.body(true, func(blockType string, labels []string, attributes map[string][]hclToken) {
+		if blockType != "variable" || len(labels) != 1 {
+			return
+		}
+		variable := TerraformVariable{Name: labels[0]}
+		if expr, ok := attributes["description"]; ok {
+			variable.Description = exprValue(expr)
+		}
+		if expr, ok := attributes["default"]; ok {
+			variable.Default = exprValue(expr)
+			variable.HasDefault = true
+		}
+		variables = append(variables, variable)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return variables, nil
+}
+
+// InputDescription returns variable description for template inputs, with its default value if any
+func (v TerraformVariable) InputDescription() string {
+	description := strings.Join(strings.Fields(v.Description), " ")
+	if description == "" {
+		description = v.Name
+	}
+	if v.HasDefault {
+		value := v.Default
+		if value == "" {
+			value = `""`
+		}
+		description += fmt.Sprintf(" [default: %s]", value)
+	}
+	return description
+}
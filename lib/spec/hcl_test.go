@@ -0,0 +1,193 @@
+package goterraspec
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTerraformVariables(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected []TerraformVariable
+	}{
+		{
+			"hcl1",
+			`variable "flavor" {
+  type        = "string"
+  description = "VM flavor"
+  default     = "m1.small"
+}
+variable image_name {
+  description = <<EOF
+Image to boot
+(centos or ubuntu)
+EOF
+}`,
+			[]TerraformVariable{
+				{Name: "flavor", Description: "VM flavor", Default: "m1.small", HasDefault: true},
+				{Name: "image_name", Description: "Image to boot\n(centos or ubuntu)"},
+			},
+		},
+		{
+			"hcl2",
+			`variable "count" {
+  type    = number
+  default = 2
+}
+variable "enabled" { default = true }
+variable "empty" {
+  type    = string
+  default = ""
+}
+variable "nodefault" {
+  type = list(string)
+}`,
+			[]TerraformVariable{
+				{Name: "count", Default: "2", HasDefault: true},
+				{Name: "enabled", Default: "true", HasDefault: true},
+				{Name: "empty", Default: "", HasDefault: true},
+				{Name: "nodefault"},
+			},
+		},
+		{
+			"indented heredoc",
+			`variable "script" {
+  description = <<-EOT
+    First line
+      indented line
+    EOT
+  default = "x"
+}`,
+			[]TerraformVariable{
+				{Name: "script", Description: "First line\n  indented line", Default: "x", HasDefault: true},
+			},
+		},
+		{
+			"interpolations",
+			`locals {
+  a = "${lookup(var.m, "k", "}")}"
+  b = "%{ if var.x }{${var.x}}%{ endif }"
+}
+variable "v" { default = "${upper("x")}" }
+variable "escaped" {
+  description = "Literal $${name} and \"quotes\""
+  default     = "${format("%s-{%s}", var.a, "b")}-suffix"
+}`,
+			[]TerraformVariable{
+				{Name: "v", Default: `${upper("x")}`, HasDefault: true},
+				{Name: "escaped", Description: `Literal ${name} and "quotes"`, Default: `${format("%s-{%s}", var.a, "b")}-suffix`, HasDefault: true},
+			},
+		},
+		{
+			"map and list defaults",
+			`variable "tags" {
+  type = map(string)
+  default = {
+    env  = "dev"
+    team = "bio"
+  }
+}
+variable "nets" {
+  default = ["a", "b"]
+}
+variable "nested" {
+  default = { ports = [22, 80], name = "web" }
+}`,
+			[]TerraformVariable{
+				{Name: "tags", Default: `{ env = "dev" team = "bio" }`, HasDefault: true},
+				{Name: "nets", Default: `["a", "b"]`, HasDefault: true},
+				{Name: "nested", Default: `{ ports = [22, 80], name = "web" }`, HasDefault: true},
+			},
+		},
+		{
+			"validation",
+			`variable "image_id" {
+  type        = string
+  description = "Image id"
+
+  validation {
+    condition     = length(var.image_id) > 4 && substr(var.image_id, 0, 4) == "ami-"
+    error_message = "The image_id value must start with \"ami-\"."
+  }
+}`,
+			[]TerraformVariable{
+				{Name: "image_id", Description: "Image id"},
+			},
+		},
+		{
+			"crlf",
+			"variable \"flavor\" {\r\n  description = \"VM flavor\"\r\n  default = \"m1.small\"\r\n}\r\nvariable \"doc\" {\r\n  description = <<EOF\r\nline 1\r\nline 2\r\nEOF\r\n}\r\n",
+			[]TerraformVariable{
+				{Name: "flavor", Description: "VM flavor", Default: "m1.small", HasDefault: true},
+				{Name: "doc", Description: "line 1\nline 2"},
+			},
+		},
+		{
+			"other blocks",
+			`# Comment
+provider "openstack" {
+  auth_url = "${var.url}"
+}
+/* variable "commented" {} */
+resource "openstack_compute_instance_v2" "vm" {
+  name = "vm"
+  variable "nested" {
+    default = "ignored"
+  }
+}
+output "ip" {
+  value = openstack_compute_instance_v2.vm.access_ip_v4
+}
+variable "kept" {}
+module "net" {
+  source   = "./net"
+  variable = "not a block"
+}`,
+			[]TerraformVariable{
+				{Name: "kept"},
+			},
+		},
+	}
+	for _, test := range tests {
+		variables, err := ParseTerraformVariables(test.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err)
+			continue
+		}
+		if len(variables) != len(test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, variables)
+			continue
+		}
+		for i, variable := range variables {
+			if variable != test.expected[i] {
+				t.Errorf("%s: expected %+v, got %+v", test.name, test.expected[i], variable)
+			}
+		}
+	}
+}
+
+func TestParseTerraformVariablesErrors(t *testing.T) {
+	tests := []struct {
+		src     string
+		message string
+	}{
+		{"variable \"x\" {\n  default = \"a\n}\n", "line 2"},
+		{"variable \"x\" {\n", "end of file"},
+		{"variable \"x\" {\n  default = [1, 2\n}\n", "line 3"},
+		{"}\n", "line 1"},
+		{"variable \"x\" {\n  description = <<EOF\nabc\n}\n", "line 2"},
+		{"variable \"x\" {\n  default = \"${upper(\"x\")\"\n}\n", "line 2"},
+		{"/* variable \"x\" {}\n", "line 1"},
+	}
+	for _, test := range tests {
+		_, err := ParseTerraformVariables(test.src)
+		if err == nil {
+			t.Errorf("%q: expected an error", test.src)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%q: expected error with %s, got %s", test.src, test.message, err)
+		}
+	}
+}
@@ -0,0 +1,892 @@
+package goterraspec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+)
+
+// Lint levels
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintProblem is a problem found in a recipe definition or script
+type LintProblem struct {
+	Level string
+	// Line is the script line, 0 for definition problems
+	Line    int
+	Message string
+}
+
+// String describes problem, prefixed by script line if any
+func (p LintProblem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%d: %s: %s", p.Line, p.Level, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.Level, p.Message)
+}
+
+// shellVariables are environment and shell variables which are not recipe inputs
+var shellVariables = map[string]bool{
+	"HOME": true, "PATH": true, "USER": true, "PWD": true, "OLDPWD": true, "SHELL": true, "HOSTNAME": true,
+	"UID": true, "EUID": true, "PPID": true, "RANDOM": true, "LINENO": true, "SECONDS": true, "IFS": true,
+	"LANG": true, "TMPDIR": true, "OSTYPE": true, "HOSTTYPE": true, "FUNCNAME": true, "PIPESTATUS": true,
+	"BASH_SOURCE": true, "BASH_VERSION": true, "BASH_REMATCH": true,
+}
+
+// LintRecipe checks recipe metadata, script shell syntax and inputs usage
+//
+// Script syntax is checked with bash -n if bash is available, else with the script lexer, see checkShell.
+// Script placeholders ${name} must be declared inputs, unless name is set in script, is a shell variable
+// or has a default value like ${name:-value}.
+// Declared inputs never referenced in script get a warning.
+func LintRecipe(recipe terraModel.Recipe) []LintProblem {
+	var problems []LintProblem
+	for _, problem := range ValidateRecipe(recipe) {
+		problems = append(problems, LintProblem{Level: LintError, Message: problem})
+	}
+	if strings.TrimSpace(recipe.Script) == "" {
+		return problems
+	}
+	script := checkShell(recipe.Script)
+	if syntax, ok := checkSyntax(recipe.Script); ok {
+		// Lexer is still used for variable references
+		script.problems = syntax
+	}
+	problems = append(problems, script.problems...)
+
+	reported := make(map[string]bool)
+	for _, ref := range script.placeholders {
+		if _, ok := recipe.Inputs[ref.name]; ok || reported[ref.name] || script.assigned[ref.name] || shellVariables[ref.name] {
+			continue
+		}
+		reported[ref.name] = true
+		problems = append(problems, LintProblem{Level: LintError, Line: ref.line, Message: fmt.Sprintf("${%s} is not a declared input", ref.name)})
+	}
+	var unused []string
+	for input := range recipe.Inputs {
+		if !script.referenced[input] {
+			unused = append(unused, input)
+		}
+	}
+	sort.Strings(unused)
+	for _, input := range unused {
+		problems = append(problems, LintProblem{Level: LintWarning, Message: fmt.Sprintf("input %s is not used in script", input)})
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return problems
+}
+
+// syntaxShell is the shell used to check script syntax
+var syntaxShell = "bash"
+
+// shellMessageRegexp matches bash messages for a script read from stdin, like "bash: line 3: syntax error..."
+var shellMessageRegexp = regexp.MustCompile(`^[^:]+: line ([0-9]+): (.*)$`)
+
+// checkSyntax checks script syntax with bash -n, ok is false if bash is not available or its output is not understood
+func checkSyntax(src string) (problems []LintProblem, ok bool) {
+	path, err := exec.LookPath(syntaxShell)
+	if err != nil {
+		return nil, false
+	}
+	cmd := exec.Command(path, "-n")
+	cmd.Stdin = strings.NewReader(src)
+	// Messages must not be translated
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+	if _, exited := runErr.(*exec.ExitError); runErr != nil && !exited {
+		return nil, false
+	}
+	failed := false
+	for _, message := range strings.Split(stderr.String(), "\n") {
+		match := shellMessageRegexp.FindStringSubmatch(message)
+		if match == nil || strings.HasPrefix(match[2], "`") {
+			// Not a message, or quote of the erroneous line
+			continue
+		}
+		line, _ := strconv.Atoi(match[1])
+		problem := LintProblem{Level: LintError, Line: line, Message: match[2]}
+		if strings.HasPrefix(match[2], "warning: ") {
+			problem.Level = LintWarning
+			problem.Message = strings.TrimPrefix(match[2], "warning: ")
+		} else {
+			failed = true
+		}
+		problems = append(problems, problem)
+	}
+	if runErr != nil && !failed {
+		return nil, false
+	}
+	return problems, true
+}
+
+// Shell tokens kinds
+const (
+	tokenWord = iota
+	tokenOperator
+	tokenNewline
+)
+
+type shellToken struct {
+	kind int
+	text string
+	line int
+}
+
+// shellRef is a variable reference in script
+type shellRef struct {
+	name string
+	line int
+}
+
+// heredoc is a here-document waiting for its body
+type heredoc struct {
+	delimiter string
+	stripTabs bool
+	quoted    bool
+	line      int
+}
+
+// shellScript is the result of a script check
+type shellScript struct {
+	problems []LintProblem
+	// placeholders are ${name} references, outside of single quotes, without default value
+	placeholders []shellRef
+	// referenced contains all referenced variable names, $name or ${name}
+	referenced map[string]bool
+	// assigned contains variables set in script
+	assigned map[string]bool
+}
+
+// shellLexer splits a shell script in tokens, like bash -n it does not expand anything
+type shellLexer struct {
+	src      string
+	pos      int
+	line     int
+	heredocs []heredoc
+	script   *shellScript
+}
+
+// shellOperators is ordered so that longest operators are matched first
+var shellOperators = []string{";;&", "<<-", "<<<", "&>>", ";;", ";&", "&&", "||", "|&", "<<", ">>", "<&", ">&", "<>", ">|", "&>", ";", "&", "|", "(", ")", "<", ">"}
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+
+// checkShell checks script syntax and collects its variable references
+//
+// Syntax check is a fallback when bash is not available. It finds unterminated quotes, substitutions and
+// here-documents, and unbalanced compound commands, but not empty command lists, like if true; then fi.
+// As with bash -n, arithmetic expressions and [[ ]] conditions are not checked.
+func checkShell(src string) *shellScript {
+	script := &shellScript{referenced: make(map[string]bool), assigned: make(map[string]bool)}
+	lexer := &shellLexer{src: src, line: 1, script: script}
+	tokens, _ := lexer.lex(false)
+	parseShell(tokens, script)
+	if len(lexer.heredocs) > 0 {
+		// Last line without new line
+		lexer.readHeredocs()
+	}
+	return script
+}
+
+func (l *shellLexer) problem(level string, line int, format string, args ...interface{}) {
+	l.script.problems = append(l.script.problems, LintProblem{Level: level, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// lex returns tokens up to end of script, or up to closing parenthesis of a command substitution
+//
+// closed is set if the closing parenthesis of the substitution was found
+func (l *shellLexer) lex(substitution bool) (tokens []shellToken, closed bool) {
+	depth := 0
+	// cases counts case commands in substitution, their patterns end with a parenthesis
+	cases := 0
+	// command is set if next word is in command position, where case and esac are reserved words
+	command := true
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '\\' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '\n':
+			l.pos += 2
+			l.line++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case c == '\n':
+			tokens = append(tokens, shellToken{kind: tokenNewline, line: l.line})
+			l.pos++
+			l.line++
+			l.readHeredocs()
+			command = true
+		case strings.HasPrefix(l.src[l.pos:], "(("):
+			line := l.line
+			l.pos += 2
+			l.readArithmetic(line)
+			tokens = append(tokens, shellToken{kind: tokenWord, text: "((", line: line})
+			command = false
+		case strings.HasPrefix(l.src[l.pos:], "<(") || strings.HasPrefix(l.src[l.pos:], ">("):
+			// Process substitution
+			line := l.line
+			l.pos++
+			l.readSubstitution(line)
+			tokens = append(tokens, shellToken{kind: tokenWord, text: "<(", line: line})
+			command = false
+		case strings.IndexByte(";&|()<>", c) >= 0:
+			op := ""
+			for _, candidate := range shellOperators {
+				if strings.HasPrefix(l.src[l.pos:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "(" {
+				depth++
+			} else if op == ")" {
+				if substitution && depth == 0 && cases == 0 {
+					l.pos++
+					return tokens, true
+				}
+				if depth > 0 {
+					depth--
+				}
+			}
+			l.pos += len(op)
+			tokens = append(tokens, shellToken{kind: tokenOperator, text: op, line: l.line})
+			if op == "<<" || op == "<<-" {
+				l.readHeredocDelimiter(op == "<<-")
+			}
+			// Redirections are followed by their target
+			command = !strings.ContainsAny(op, "<>")
+		default:
+			line := l.line
+			word := l.readWord()
+			if command && word == "case" {
+				cases++
+			} else if command && word == "esac" && cases > 0 {
+				cases--
+			}
+			switch word {
+			case "then", "do", "else", "elif", "if", "while", "until", "{", "!", "time":
+				command = true
+			default:
+				command = assignRegexp.MatchString(word)
+			}
+			tokens = append(tokens, shellToken{kind: tokenWord, text: word, line: line})
+		}
+	}
+	return tokens, false
+}
+
+// isWordEnd checks if character ends an unquoted word
+func isWordEnd(c byte) bool {
+	return strings.IndexByte(" \t\r\n;&|()<>", c) >= 0
+}
+
+// readWord reads a word, with its quotes and expansions
+func (l *shellLexer) readWord() string {
+	start := l.pos
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case isWordEnd(c):
+			if c == '(' && l.pos > start && l.src[l.pos-1] == '=' {
+				// Array assignment
+				line := l.line
+				l.pos++
+				l.readUntil(')', line, "array assignment")
+				continue
+			}
+			return l.src[start:l.pos]
+		case c == '\\':
+			l.pos += 2
+			if l.pos <= len(l.src) && l.src[l.pos-1] == '\n' {
+				l.line++
+			}
+		case c == '\'':
+			l.readSingleQuote()
+		case c == '"':
+			l.readDoubleQuote()
+		case c == '`':
+			l.readBackquote()
+		case strings.HasPrefix(l.src[l.pos:], "$'"):
+			l.readANSIQuote()
+		case c == '$':
+			l.readDollar()
+		default:
+			l.pos++
+		}
+	}
+	if l.pos > len(l.src) {
+		l.pos = len(l.src)
+	}
+	return l.src[start:l.pos]
+}
+
+// countLines updates current line with new lines of text
+func (l *shellLexer) countLines(text string) {
+	l.line += strings.Count(text, "\n")
+}
+
+func (l *shellLexer) readSingleQuote() {
+	line := l.line
+	end := strings.IndexByte(l.src[l.pos+1:], '\'')
+	if end < 0 {
+		l.problem(LintError, line, "unterminated single quote")
+		l.countLines(l.src[l.pos:])
+		l.pos = len(l.src)
+		return
+	}
+	l.countLines(l.src[l.pos : l.pos+end+2])
+	l.pos += end + 2
+}
+
+// readANSIQuote reads a $'...' string, where quotes may be escaped with \
+func (l *shellLexer) readANSIQuote() {
+	line := l.line
+	l.pos += 2
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '\'':
+			l.pos++
+			return
+		case '\\':
+			l.pos++
+			if l.pos < len(l.src) && l.src[l.pos] == '\n' {
+				l.line++
+			}
+			l.pos++
+		case '\n':
+			l.line++
+			l.pos++
+		default:
+			l.pos++
+		}
+	}
+	l.problem(LintError, line, "unterminated $' quote")
+}
+
+func (l *shellLexer) readDoubleQuote() {
+	line := l.line
+	l.pos++
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '"':
+			l.pos++
+			return
+		case '\\':
+			l.pos++
+			if l.pos < len(l.src) && l.src[l.pos] == '\n' {
+				l.line++
+			}
+			l.pos++
+		case '\n':
+			l.line++
+			l.pos++
+		case '`':
+			l.readBackquote()
+		case '$':
+			l.readDollar()
+		default:
+			l.pos++
+		}
+	}
+	l.problem(LintError, line, "unterminated double quote")
+}
+
+func (l *shellLexer) readBackquote() {
+	line := l.line
+	l.pos++
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '`':
+			l.pos++
+			return
+		case '\\':
+			l.pos++
+			if l.pos < len(l.src) && l.src[l.pos] == '\n' {
+				l.line++
+			}
+			l.pos++
+		case '\n':
+			l.line++
+			l.pos++
+		case '$':
+			l.readDollar()
+		default:
+			l.pos++
+		}
+	}
+	l.problem(LintError, line, "unterminated backquote")
+}
+
+// readUntil reads up to closing character, skipping quotes, nested expansions and parenthesis
+func (l *shellLexer) readUntil(closing byte, line int, what string) {
+	depth := 0
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == closing && depth == 0:
+			l.pos++
+			return
+		case c == '(' && closing == ')':
+			depth++
+			l.pos++
+		case c == ')' && closing == ')':
+			depth--
+			l.pos++
+		case c == '\\':
+			l.pos++
+			if l.pos < len(l.src) && l.src[l.pos] == '\n' {
+				l.line++
+			}
+			l.pos++
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == '\'':
+			l.readSingleQuote()
+		case c == '"':
+			l.readDoubleQuote()
+		case c == '`':
+			l.readBackquote()
+		case strings.HasPrefix(l.src[l.pos:], "$'"):
+			l.readANSIQuote()
+		case c == '$':
+			l.readDollar()
+		default:
+			l.pos++
+		}
+	}
+	l.problem(LintError, line, "unterminated %s", what)
+}
+
+// readArithmetic reads an arithmetic expression up to closing ))
+func (l *shellLexer) readArithmetic(line int) {
+	l.readUntil(')', line, "arithmetic expression")
+	if l.pos < len(l.src) && l.src[l.pos] == ')' {
+		l.pos++
+	} else if l.pos >= len(l.src) {
+		return
+	} else {
+		l.problem(LintError, line, "arithmetic expression not closed by ))")
+	}
+}
+
+// readDollar reads a $ expansion: variable, ${...}, $(...) or $((...))
+func (l *shellLexer) readDollar() {
+	line := l.line
+	l.pos++
+	rest := l.src[l.pos:]
+	switch {
+	case strings.HasPrefix(rest, "(("):
+		l.pos += 2
+		l.readArithmetic(line)
+	case strings.HasPrefix(rest, "("):
+		l.readSubstitution(line)
+	case strings.HasPrefix(rest, "{"):
+		l.pos++
+		expansion := strings.TrimLeft(l.src[l.pos:], "!#")
+		name := identifierRegexp.FindString(expansion)
+		if name != "" {
+			if !hasDefaultValue(expansion[len(name):]) {
+				l.script.placeholders = append(l.script.placeholders, shellRef{name: name, line: line})
+			}
+			l.script.referenced[name] = true
+		}
+		l.readUntil('}', line, "parameter expansion ${")
+	default:
+		if name := identifierRegexp.FindString(rest); name != "" {
+			l.script.referenced[name] = true
+			l.pos += len(name)
+		}
+	}
+}
+
+// defaultOperators are parameter expansion operators giving a value to unset variables
+var defaultOperators = []string{":-", ":=", ":+", "-", "=", "+"}
+
+// hasDefaultValue checks if the rest of a ${name...} expansion, after name, gives a default value
+func hasDefaultValue(rest string) bool {
+	for _, operator := range defaultOperators {
+		if strings.HasPrefix(rest, operator) {
+			return true
+		}
+	}
+	return false
+}
+
+// readSubstitution checks the commands of a $(...) or <(...) substitution, starting at its opening parenthesis
+func (l *shellLexer) readSubstitution(line int) {
+	l.pos++
+	tokens, closed := l.lex(true)
+	if !closed {
+		l.problem(LintError, line, "unterminated command substitution")
+	}
+	parseShell(tokens, l.script)
+}
+
+// readHeredocDelimiter reads the delimiter word following << and records the pending here-document
+func (l *shellLexer) readHeredocDelimiter(stripTabs bool) {
+	for l.pos < len(l.src) && (l.src[l.pos] == ' ' || l.src[l.pos] == '\t') {
+		l.pos++
+	}
+	line := l.line
+	if l.pos >= len(l.src) || isWordEnd(l.src[l.pos]) {
+		l.problem(LintError, line, "missing here-document delimiter")
+		return
+	}
+	word := l.readWord()
+	delimiter := strings.NewReplacer("'", "", "\"", "", "\\", "").Replace(word)
+	l.heredocs = append(l.heredocs, heredoc{delimiter: delimiter, stripTabs: stripTabs, quoted: delimiter != word, line: line})
+}
+
+// placeholderRegexp matches ${name} and $name references in here-documents
+var placeholderRegexp = regexp.MustCompile(`\$(\{[!#]?)?([A-Za-z_][A-Za-z0-9_]*)(:?[-=+])?`)
+
+// readHeredocs reads bodies of pending here-documents, starting at current line
+func (l *shellLexer) readHeredocs() {
+	for _, doc := range l.heredocs {
+		closed := false
+		for l.pos < len(l.src) {
+			end := strings.IndexByte(l.src[l.pos:], '\n')
+			text := l.src[l.pos:]
+			if end >= 0 {
+				text = l.src[l.pos : l.pos+end]
+				l.pos += end + 1
+			} else {
+				l.pos = len(l.src)
+			}
+			line := l.line
+			l.line++
+			check := text
+			if doc.stripTabs {
+				check = strings.TrimLeft(check, "\t")
+			}
+			if check == doc.delimiter {
+				closed = true
+				break
+			}
+			if doc.quoted {
+				continue
+			}
+			for _, match := range placeholderRegexp.FindAllStringSubmatch(text, -1) {
+				l.script.referenced[match[2]] = true
+				if match[1] != "" && match[3] == "" {
+					l.script.placeholders = append(l.script.placeholders, shellRef{name: match[2], line: line})
+				}
+			}
+		}
+		if !closed {
+			l.problem(LintWarning, doc.line, "here-document delimited by end of file (wanted %s)", doc.delimiter)
+		}
+	}
+	l.heredocs = nil
+}
+
+// shellBlock is a compound command waiting for its closing word
+type shellBlock struct {
+	// kind is the opening word, or state for case: case (subject), in, pattern, command
+	kind string
+	line int
+}
+
+// shellClosing gives the expected closing word of blocks
+var shellClosing = map[string]string{
+	"if": "fi", "then": "fi", "else": "fi", "while": "do", "until": "do", "for": "do", "select": "do", "do": "done",
+	"{": "}", "(": ")", "case": "esac", "in": "esac", "pattern": "esac", "command": "esac",
+}
+
+// assignRegexp matches variable assignments like name=value or name+=value
+var assignRegexp = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)(\[[^\]]*\])?\+?=`)
+
+// parseShell checks compound commands nesting and records assigned variables
+func parseShell(tokens []shellToken, script *shellScript) {
+	var stack []shellBlock
+	problem := func(line int, format string, args ...interface{}) {
+		script.problems = append(script.problems, LintProblem{Level: LintError, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+	top := func() string {
+		if len(stack) == 0 {
+			return ""
+		}
+		return stack[len(stack)-1].kind
+	}
+	setTop := func(kind string) {
+		stack[len(stack)-1].kind = kind
+	}
+	pop := func() {
+		stack = stack[:len(stack)-1]
+	}
+	unexpected := func(token shellToken) {
+		text := token.text
+		if token.kind == tokenNewline {
+			text = "newline"
+		}
+		problem(token.line, "syntax error near unexpected token `%s'", text)
+	}
+
+	commandStart := true
+	// declaring is set after commands whose arguments are variable names (read, for...)
+	declaring := false
+	condition := false
+	// defining is set after function keyword, before function name
+	defining := false
+	previous := shellToken{kind: tokenNewline}
+	for i, token := range tokens {
+		switch token.kind {
+		case tokenNewline:
+			if top() == "case" {
+				unexpected(token)
+			}
+			if top() != "in" {
+				commandStart = true
+			}
+			declaring = false
+		case tokenOperator:
+			switch token.text {
+			case ";", "&":
+				if emptyCommand(i, previous) && top() != "pattern" {
+					unexpected(token)
+				}
+				commandStart = true
+				declaring = false
+			case "&&", "||", "|", "|&":
+				if condition || top() == "pattern" {
+					break
+				}
+				if emptyCommand(i, previous) {
+					unexpected(token)
+				}
+				commandStart = true
+				declaring = false
+			case ";;", ";&", ";;&":
+				if top() != "command" {
+					unexpected(token)
+					break
+				}
+				setTop("pattern")
+				commandStart = true
+			case "(":
+				if condition || top() == "pattern" {
+					break
+				}
+				if previous.kind == tokenWord && i+1 < len(tokens) && tokens[i+1].text == ")" {
+					// Function definition, name ()
+					break
+				}
+				if commandStart {
+					stack = append(stack, shellBlock{kind: "(", line: token.line})
+					break
+				}
+				unexpected(token)
+			case ")":
+				if condition {
+					break
+				}
+				if previous.text == "(" && previous.kind == tokenOperator && top() != "(" {
+					// Function definition, body follows
+					commandStart = true
+					break
+				}
+				switch top() {
+				case "pattern":
+					setTop("command")
+					commandStart = true
+				case "(":
+					pop()
+					commandStart = false
+				default:
+					unexpected(token)
+				}
+			default:
+				// Redirections, target is the next word
+				if i+1 >= len(tokens) || tokens[i+1].kind != tokenWord {
+					if token.text != "<<" && token.text != "<<-" {
+						next := shellToken{kind: tokenNewline, line: token.line}
+						if i+1 < len(tokens) {
+							next = tokens[i+1]
+						}
+						unexpected(next)
+					}
+				}
+			}
+		case tokenWord:
+			if previous.kind == tokenOperator && strings.ContainsAny(previous.text, "<>") {
+				// Redirection target
+				break
+			}
+			word := token.text
+			if condition {
+				if word == "]]" {
+					condition = false
+					commandStart = false
+				}
+				break
+			}
+			switch top() {
+			case "case":
+				setTop("in")
+				previous = token
+				continue
+			case "in":
+				if word != "in" {
+					problem(token.line, "expecting `in' after case subject, got `%s'", word)
+				}
+				setTop("pattern")
+				previous = token
+				continue
+			case "pattern":
+				if word == "esac" {
+					pop()
+					commandStart = false
+				}
+				previous = token
+				continue
+			}
+			if declaring && identifierRegexp.FindString(word) == word {
+				script.assigned[word] = true
+			}
+			if match := assignRegexp.FindStringSubmatch(word); match != nil {
+				script.assigned[match[1]] = true
+			}
+			if defining {
+				defining = false
+				commandStart = true
+				break
+			}
+			if !commandStart {
+				break
+			}
+			commandStart = false
+			switch word {
+			case "if", "while", "until":
+				stack = append(stack, shellBlock{kind: word, line: token.line})
+				commandStart = true
+			case "for", "select":
+				stack = append(stack, shellBlock{kind: word, line: token.line})
+				if i+1 < len(tokens) && tokens[i+1].kind == tokenWord {
+					script.assigned[tokens[i+1].text] = true
+				}
+			case "case":
+				stack = append(stack, shellBlock{kind: word, line: token.line})
+			case "{":
+				stack = append(stack, shellBlock{kind: word, line: token.line})
+				commandStart = true
+			case "!", "time":
+				commandStart = true
+			case "function":
+				defining = true
+			case "[[":
+				condition = true
+			case "then":
+				if top() != "if" {
+					unexpected(token)
+					break
+				}
+				setTop("then")
+				commandStart = true
+			case "elif":
+				if top() != "then" {
+					unexpected(token)
+					break
+				}
+				setTop("if")
+				commandStart = true
+			case "else":
+				if top() != "then" {
+					unexpected(token)
+					break
+				}
+				setTop("else")
+				commandStart = true
+			case "fi":
+				if top() != "then" && top() != "else" {
+					unexpected(token)
+					break
+				}
+				pop()
+			case "do":
+				switch top() {
+				case "while", "until", "for", "select":
+					setTop("do")
+					commandStart = true
+				default:
+					unexpected(token)
+				}
+			case "done":
+				if top() != "do" {
+					unexpected(token)
+					break
+				}
+				pop()
+			case "esac":
+				if top() != "command" {
+					unexpected(token)
+					break
+				}
+				pop()
+			case "}":
+				if top() != "{" {
+					unexpected(token)
+					break
+				}
+				pop()
+			case "read", "local", "declare", "typeset", "export", "readonly", "getopts":
+				declaring = true
+			default:
+				if assignRegexp.MatchString(word) {
+					// Assignments may precede the command
+					commandStart = true
+				}
+			}
+		}
+		previous = token
+	}
+	if condition {
+		problem(previous.line, "[[ is not closed by ]]")
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		problem(stack[i].line, "%s is not closed by %s", openingWord(stack[i].kind), shellClosing[stack[i].kind])
+	}
+}
+
+// emptyCommand checks if token i, preceded by previous, starts a line or follows a command separator
+func emptyCommand(i int, previous shellToken) bool {
+	if i == 0 || previous.kind == tokenNewline {
+		return true
+	}
+	if previous.kind != tokenOperator {
+		return false
+	}
+	switch previous.text {
+	case ";", "&", "&&", "||", "|", "|&":
+		return true
+	}
+	return false
+}
+
+// openingWord returns the word which opened a block
+func openingWord(kind string) string {
+	switch kind {
+	case "then", "else":
+		return "if"
+	case "do":
+		return "loop"
+	case "in", "pattern", "command":
+		return "case"
+	}
+	return kind
+}
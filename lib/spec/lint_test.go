@@ -0,0 +1,136 @@
+package goterraspec
+
+import (
+	"os/exec"
+	"testing"
+
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+)
+
+// lintErrors returns the error problems of a script check
+func lintErrors(src string) []LintProblem {
+	var errors []LintProblem
+	for _, problem := range checkShell(src).problems {
+		if problem.Level == LintError {
+			errors = append(errors, problem)
+		}
+	}
+	return errors
+}
+
+func TestCheckShellValid(t *testing.T) {
+	scripts := []struct {
+		name string
+		src  string
+	}{
+		{"commands", "apt-get update\nexport X=1\n[ -z \"$X\" ] || echo ok\n"},
+		{"if", "if true\nthen\n  echo a\nelif false; then\n  echo b\nelse\n  echo c\nfi\n"},
+		{"loops", "for i in 1 2; do echo $i; done\nwhile true\ndo\n  break\ndone\n"},
+		{"inline case", "case $x in a|b) echo a;; (c) echo c;; *) echo d;; esac\n"},
+		{"multiline case", "case $x in\n  a)\n    echo a\n    ;;\n  *) echo d\nesac\n"},
+		{"case in substitution", "x=$(case a in a) echo a;; esac)\n"},
+		{"case word in substitution", "x=$(echo case)\ny=$(echo case esac)\n"},
+		{"heredoc", "cat <<EOF\nhello ${name}\n)fi\nEOF\necho after\n"},
+		{"quoted heredoc", "cat <<'EOF'\n$(\nEOF\n"},
+		{"indented heredoc", "if true; then\n\tcat <<-EOF\n\thello\n\tEOF\nfi\n"},
+		{"functions", "f() {\n  echo a\n}\nfunction g {\n  echo b\n}\nfunction h() { echo c; }\nf\n"},
+		{"substitutions", "echo $(date +%s) \"a $(echo \"b)\")\" ${x:-$(echo y)}\n"},
+		{"backquotes", "echo `date` \"`echo )`\"\n"},
+		{"conditions", "if [[ -f /x && ( $a == b ) ]]; then echo a; fi\n"},
+		{"arrays", "arr=(a b c)\necho ${arr[1]} ${#arr[@]}\n"},
+		{"arithmetic", "for ((i=0;i<3;i++)); do echo $((i*2)); done\n(( i > 1 )) && echo big\n"},
+		{"subshell", "( cd /tmp && ls )\n{ echo a; echo b; } > /tmp/x\n"},
+		{"process substitution", "diff <(ls) <(ls /tmp)\n"},
+		{"ansi-c quotes", "echo $'a\\'b' $'c\\nd'\n"},
+		{"default values", "echo ${FOO:-bar} ${A-b} ${C:=d} ${E:+f}\n"},
+		{"comments", "echo a # comment ) fi\n"},
+		{"line continuation", "echo a \\\n  b\n"},
+	}
+	for _, script := range scripts {
+		if errors := lintErrors(script.src); len(errors) > 0 {
+			t.Errorf("%s: unexpected errors %v", script.name, errors)
+		}
+	}
+}
+
+func TestCheckShellErrors(t *testing.T) {
+	scripts := []struct {
+		name string
+		src  string
+		line int
+	}{
+		{"unclosed if", "if true; then\n  echo a\n", 1},
+		{"unclosed loop", "for i in 1 2; do\n  echo $i\n", 1},
+		{"mismatched fi", "if true; then\n  for i in a; do\n    echo $i\n  fi\ndone\n", 4},
+		{"unexpected done", "echo a\ndone\n", 2},
+		{"unclosed case", "case $x in\n  a) echo a;;\n", 1},
+		{"unclosed single quote", "echo ok\necho 'a\n", 2},
+		{"unclosed double quote", "echo \"a\n", 1},
+		{"unclosed ansi-c quote", "echo $'a\\'\n", 1},
+		{"unclosed backquote", "echo `date\n", 1},
+		{"unclosed substitution", "echo $(date\n", 1},
+		{"unclosed expansion", "echo ${x\n", 1},
+		{"unclosed arithmetic", "echo $((1+2)\n", 1},
+		{"unclosed condition", "[[ -f /x\n", 1},
+		{"unclosed brace", "{ echo a\n", 1},
+		{"unexpected parenthesis", "echo a )\n", 1},
+		{"empty command", "echo a && && echo b\n", 1},
+		{"missing redirection target", "ls >\n", 1},
+	}
+	for _, script := range scripts {
+		errors := lintErrors(script.src)
+		if len(errors) == 0 {
+			t.Errorf("%s: expected an error", script.name)
+			continue
+		}
+		if errors[0].Line != script.line {
+			t.Errorf("%s: expected error on line %d, got %v", script.name, script.line, errors)
+		}
+	}
+}
+
+func TestLintRecipeInputs(t *testing.T) {
+	recipe := terraModel.Recipe{
+		Name:        "test",
+		Description: "test recipe",
+		Script:      "NAME=1\necho ${NAME} ${flavor} ${missing} ${opt:-none} ${HOME} '${quoted}'\ncat <<EOF\n${doc} ${other:-x}\nEOF\n",
+		Inputs:      map[string]string{"flavor": "VM flavor", "doc": "documentation", "unused": "not used"},
+	}
+	expected := []string{
+		"warning: input unused is not used in script",
+		"2: error: ${missing} is not a declared input",
+	}
+	problems := LintRecipe(recipe)
+	if len(problems) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, problems)
+	}
+	for i, problem := range problems {
+		if problem.String() != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], problem)
+		}
+	}
+}
+
+func TestLintRecipeSyntax(t *testing.T) {
+	recipe := terraModel.Recipe{
+		Name:        "test",
+		Description: "test recipe",
+		Script:      "if true; then\nfi\necho 'done\n",
+	}
+	defer func(shell string) { syntaxShell = shell }(syntaxShell)
+	syntaxShell = "goterra-missing-shell"
+	// Lexer does not find empty command lists
+	problems := LintRecipe(recipe)
+	if len(problems) != 1 || problems[0].Line != 3 || problems[0].Message != "unterminated single quote" {
+		t.Errorf("expected lexer error on line 3, got %v", problems)
+	}
+
+	syntaxShell = "bash"
+	if _, err := exec.LookPath(syntaxShell); err != nil {
+		t.Skip("bash is not available")
+	}
+	problems = LintRecipe(recipe)
+	if len(problems) == 0 || problems[0].Level != LintError || problems[0].Line != 2 {
+		t.Errorf("expected bash error on line 2, got %v", problems)
+	}
+}
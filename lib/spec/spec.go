@@ -0,0 +1,223 @@
+package goterraspec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v2"
+
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+)
+
+// Load reads a yaml (or json) object definition file into obj
+//
+// Fields use the same names as Goterra API and json/yaml output, unknown fields are rejected
+func Load(path string, obj interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := Decode(data, obj); err != nil {
+		return fmt.Errorf("invalid definition %s: %s", path, err)
+	}
+	return nil
+}
+
+// Decode decodes a yaml (or json) object definition into obj
+func Decode(data []byte, obj interface{}) error {
+	var generic interface{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+	if generic == nil {
+		return fmt.Errorf("empty definition")
+	}
+	jsonData, err := json.Marshal(toJSONValue(generic))
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(obj)
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		return fmt.Errorf("field %s expects a %s value, got %s (quote it if needed)", typeErr.Field, typeErr.Type, typeErr.Value)
+	}
+	return err
+}
+
+// toJSONValue converts yaml maps with interface{} keys to string keys maps
+func toJSONValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(typed))
+		for key, elt := range typed {
+			converted[fmt.Sprintf("%v", key)] = toJSONValue(elt)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(typed))
+		for i, elt := range typed {
+			converted[i] = toJSONValue(elt)
+		}
+		return converted
+	}
+	return value
+}
+
+// checkKeys reports empty keys of a map field
+func checkKeys(field string, values map[string]string) []string {
+	var problems []string
+	for key := range values {
+		if strings.TrimSpace(key) == "" {
+			problems = append(problems, fmt.Sprintf("%s has an empty key", field))
+		}
+	}
+	return problems
+}
+
+// ValidateEndpoint checks endpoint required fields, it returns the list of problems found
+func ValidateEndpoint(endpoint terraModel.EndPoint) []string {
+	var problems []string
+	if strings.TrimSpace(endpoint.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+	if strings.TrimSpace(endpoint.Kind) == "" {
+		problems = append(problems, "kind is required (openstack, ...)")
+	} else if strings.ToLower(endpoint.Kind) != endpoint.Kind || strings.ContainsAny(endpoint.Kind, " \t") {
+		problems = append(problems, fmt.Sprintf("kind %q must be a lowercase word", endpoint.Kind))
+	}
+	problems = append(problems, checkKeys("config", endpoint.Config)...)
+	problems = append(problems, checkKeys("inputs", endpoint.Inputs)...)
+	problems = append(problems, checkKeys("images", endpoint.Images)...)
+	return problems
+}
+
+// checkID reports an invalid object id reference
+func checkID(field string, id string) []string {
+	if id == "" {
+		return nil
+	}
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return []string{fmt.Sprintf("%s %q is not a valid id", field, id)}
+	}
+	return nil
+}
+
+// ValidateRecipe checks recipe required fields, it returns the list of problems found
+func ValidateRecipe(recipe terraModel.Recipe) []string {
+	var problems []string
+	if strings.TrimSpace(recipe.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+	if strings.TrimSpace(recipe.Description) == "" {
+		problems = append(problems, "description is required")
+	}
+	if strings.TrimSpace(recipe.Script) == "" {
+		problems = append(problems, "script is required")
+	}
+	problems = append(problems, checkID("parent", recipe.ParentRecipe)...)
+	problems = append(problems, checkKeys("inputs", recipe.Inputs)...)
+	for _, tag := range recipe.Tags {
+		if strings.TrimSpace(tag) == "" {
+			problems = append(problems, "tags has an empty tag")
+		}
+	}
+	return problems
+}
+
+// ValidateTemplate checks template required fields, it returns the list of problems found
+func ValidateTemplate(template terraModel.Template) []string {
+	var problems []string
+	if strings.TrimSpace(template.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+	if len(template.Data) == 0 {
+		problems = append(problems, "data is required, with terraform files per endpoint kind")
+	}
+	for kind, data := range template.Data {
+		if strings.TrimSpace(data) == "" {
+			problems = append(problems, fmt.Sprintf("data of %s is empty", kind))
+		}
+	}
+	problems = append(problems, checkKeys("data", template.Data)...)
+	problems = append(problems, checkKeys("inputs", template.Inputs)...)
+	for _, tag := range template.Tags {
+		if strings.TrimSpace(tag) == "" {
+			problems = append(problems, "tags has an empty tag")
+		}
+	}
+	return problems
+}
+
+// Invalid returns an error listing problems of an object definition, nil if there is none
+func Invalid(kind string, problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid %s definition:\n  * %s", kind, strings.Join(problems, "\n  * "))
+}
+
+// Recipe directory file names, as written by WriteRecipe
+const (
+	RecipeFile = "recipe.yaml"
+	ScriptFile = "install.sh"
+	ParentDir  = "parent"
+)
+
+// Save writes obj as a yaml definition file, with header as comment
+//
+// Fields listed in omit, and empty fields, are not written
+func Save(path string, obj interface{}, header string, omit ...string) error {
+	jsonData, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	var generic map[string]interface{}
+	if err := json.Unmarshal(jsonData, &generic); err != nil {
+		return err
+	}
+	for _, field := range omit {
+		delete(generic, field)
+	}
+	for field, value := range generic {
+		if value == nil || value == "" {
+			delete(generic, field)
+		}
+	}
+	yamlData, err := yaml.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	var content bytes.Buffer
+	for _, line := range strings.Split(strings.TrimSpace(header), "\n") {
+		if line != "" {
+			fmt.Fprintf(&content, "# %s\n", line)
+		}
+	}
+	content.Write(yamlData)
+	return ioutil.WriteFile(path, content.Bytes(), 0644)
+}
+
+// WriteRecipe writes recipe definition and its executable script in dir
+//
+// Server managed fields (id, namespace, version...) are not part of the definition,
+// so that it can be used as is to create or update a recipe
+func WriteRecipe(dir string, recipe terraModel.Recipe) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	header := fmt.Sprintf("recipe %s, namespace %s, version %s\nscript: %s", recipe.ID.Hex(), recipe.Namespace, recipe.Version, ScriptFile)
+	if recipe.ParentRecipe != "" {
+		header += fmt.Sprintf("\nparent recipe: %s", ParentDir)
+	}
+	if err := Save(filepath.Join(dir, RecipeFile), recipe, header, "id", "namespace", "ts", "prev", "version", "script"); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, ScriptFile), []byte(recipe.Script), 0755)
+}
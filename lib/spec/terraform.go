@@ -0,0 +1,74 @@
+package goterraspec
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	terraModel "github.com/osallou/goterra-lib/lib/model"
+)
+
+// ReadTerraformDir concatenates the .tf files of dir, in name order, and returns their variables
+func ReadTerraformDir(dir string) (string, []TerraformVariable, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	var data bytes.Buffer
+	var variables []TerraformVariable
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".tf" {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", nil, err
+		}
+		fileVariables, err := ParseTerraformVariables(string(content))
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid terraform file %s: %s", path, err)
+		}
+		variables = append(variables, fileVariables...)
+		fmt.Fprintf(&data, "# %s\n%s", file.Name(), content)
+		if !bytes.HasSuffix(content, []byte("\n")) {
+			data.WriteString("\n")
+		}
+	}
+	if data.Len() == 0 {
+		return "", nil, fmt.Errorf("no terraform (.tf) file in %s", dir)
+	}
+	return data.String(), variables, nil
+}
+
+// AddTerraformDir sets template data for endpoint kind from dir terraform files
+//
+// Variables not already in template inputs are added, with their description and default value.
+// It returns the names of added inputs.
+func AddTerraformDir(template *terraModel.Template, kind string, dir string) ([]string, error) {
+	if strings.TrimSpace(kind) == "" {
+		return nil, fmt.Errorf("missing endpoint kind for %s", dir)
+	}
+	data, variables, err := ReadTerraformDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if template.Data == nil {
+		template.Data = make(map[string]string)
+	}
+	template.Data[kind] = data
+	if template.Inputs == nil {
+		template.Inputs = make(map[string]string)
+	}
+	var added []string
+	for _, variable := range variables {
+		if _, ok := template.Inputs[variable.Name]; ok {
+			continue
+		}
+		template.Inputs[variable.Name] = variable.InputDescription()
+		added = append(added, variable.Name)
+	}
+	return added, nil
+}